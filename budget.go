@@ -0,0 +1,113 @@
+package prowlgo
+
+import (
+	"fmt"
+	"time"
+)
+
+// Status tells what the client did with a notification passed to Send.
+type Status int
+
+const (
+	//StatusSent means that the notification was sent to the prowl server. Check the error and
+	//Result.Delivered to find out if it was delivered.
+	StatusSent Status = iota
+	//StatusQueued means that the notification was put into the outbox (see OutboxConfig).
+	StatusQueued
+	//StatusDropped means that the notification was not sent because the remaining api calls
+	//are reserved for notifications of higher priority (see BudgetPolicy), because sending it
+	//would exceed the pace of the client (see PacingConfig), because its recipients are in
+	//their quiet hours (see QuietHours) or because nobody subscribed to its topic.
+	StatusDropped
+	//StatusDigested means that the notification is held and will be sent as part of a
+	//summary (see DigestConfig).
+	StatusDigested
+	//StatusSuppressed means that the notification was not sent because it repeats a
+	//notification sent shortly before (see ThrottleConfig).
+	StatusSuppressed
+	//StatusDeferred means that the notification is held until the quiet hours of its
+	//recipients end (see QuietHours).
+	StatusDeferred
+	//StatusSilenced means that the notification was not sent because it matches a silence
+	//(see Silence).
+	StatusSilenced
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusSent:
+		return "sent"
+	case StatusQueued:
+		return "queued"
+	case StatusDropped:
+		return "dropped"
+	case StatusDigested:
+		return "digested"
+	case StatusSuppressed:
+		return "suppressed"
+	case StatusDeferred:
+		return "deferred"
+	case StatusSilenced:
+		return "silenced"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// BudgetPolicy keeps some of the api calls of the hourly limit for important notifications
+// (see Config.Budget). Once the remaining api calls reach the reserve, notifications of lower
+// priority are refused with an error matching ErrReserved. They are either dropped or put into
+// the outbox, which delivers them once the api call limit is reset. A notification sent to more
+// than five api keys takes one api call per five keys and is refused if these calls would eat
+// into the reserve.
+//
+// A notification of a higher priority never waits for queued notifications held back by the
+// budget.
+type BudgetPolicy struct {
+	//Reserve maps priorities to the number of api calls kept for notifications of this or a
+	//higher priority. {PrioHigh: 50} keeps the last 50 calls for PrioHigh and PrioEmergency.
+	//{PrioHigh: 50, PrioNormal: 200} additionally keeps 200 calls for notifications with at
+	//least PrioNormal.
+	Reserve map[int]int
+
+	//Queue puts refused notifications into the outbox instead of dropping them. Requires
+	//Config.Outbox.
+	Queue bool
+}
+
+func (bp *BudgetPolicy) validate() error {
+	reserve := make(map[int]int, len(bp.Reserve))
+	for prio, calls := range bp.Reserve {
+		if prio <= PrioVeryLow || prio > PrioEmergency {
+			return newFieldError("Budget", "calls can only be reserved for priorities in the range -1..2")
+		}
+		if calls < 0 {
+			return newFieldError("Budget", "number of reserved calls must not be negative")
+		}
+		reserve[prio] = calls
+	}
+	bp.Reserve = reserve
+	return nil
+}
+
+// reserved returns the number of api calls a notification of the given priority must leave
+// to notifications of higher priority.
+func (bp *BudgetPolicy) reserved(priority int) (calls int) {
+	if bp == nil {
+		return 0
+	}
+	for prio, c := range bp.Reserve {
+		if prio > priority && c > calls {
+			calls = c
+		}
+	}
+	return
+}
+
+// checkBudget returns an error matching ErrReserved if a notification of the given priority
+// must not use the given number of the remaining api calls.
+func checkBudget(bp *BudgetPolicy, priority int, calls int, remaining int, reset time.Time) error {
+	if reserved := bp.reserved(priority); remaining-calls < reserved && reset.After(time.Now()) {
+		return fmt.Errorf("%w: the last %d api calls are kept for notifications of higher priority", ErrReserved, reserved)
+	}
+	return nil
+}
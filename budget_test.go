@@ -0,0 +1,205 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+	"github.com/tweithoener/prowlgo/prowltest"
+)
+
+func TestBudget(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	for _, bp := range []prowl.BudgetPolicy{
+		{Reserve: map[int]int{prowl.PrioVeryLow: 10}},
+		{Reserve: map[int]int{3: 10}},
+		{Reserve: map[int]int{prowl.PrioHigh: -1}},
+		{Reserve: map[int]int{prowl.PrioHigh: 10}, Queue: true},
+	} {
+		bp := bp
+		if _, err := prowl.NewClient(prowl.Config{Budget: &bp}); !errors.Is(err, prowl.ErrInvalidArgument) {
+			t.Errorf("invalid budget %+v should produce an error: %v", bp, err)
+		}
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Budget:  &prowl.BudgetPolicy{Reserve: map[int]int{prowl.PrioHigh: 50, prowl.PrioNormal: 100}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(prio int) (prowl.Result, error) {
+		return client.Send(context.Background(), prowl.Notification{Priority: prio, Event: "Event"})
+	}
+
+	mock.SetRemaining(102)
+	if result, err := send(prowl.PrioModerate); err != nil || result.Status != prowl.StatusSent || result.Remaining != 101 {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+	if _, err := send(prowl.PrioModerate); err != nil {
+		t.Error(err)
+	}
+
+	//only 100 calls left: they are kept for normal and higher priorities
+	requests := len(mock.Requests())
+	result, err := send(prowl.PrioModerate)
+	if !errors.Is(err, prowl.ErrReserved) || result.Status != prowl.StatusDropped {
+		t.Errorf("notification should have been dropped %+v: %v", result, err)
+	}
+	if len(mock.Requests()) != requests {
+		t.Error("dropped notification should not be sent")
+	}
+	mock.SetRemaining(51)
+	if result, err := send(prowl.PrioNormal); err != nil || result.Status != prowl.StatusSent {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+	if _, err := send(prowl.PrioNormal); !errors.Is(err, prowl.ErrReserved) {
+		t.Errorf("notification should have been dropped: %v", err)
+	}
+	if result, err := send(prowl.PrioHigh); err != nil || result.Status != prowl.StatusSent {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+
+	//once the limit is reset everything goes out again
+	mock.SetRemaining(prowltest.DefaultRemaining)
+	mock.SetResetDate(time.Now().Add(-1 * time.Minute))
+	if _, err := send(prowl.PrioEmergency); err != nil {
+		t.Error(err)
+	}
+	if _, err := send(prowl.PrioVeryLow); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBudgetBatches(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	var keys []string
+	for i := 0; i < 12; i++ {
+		keys = append(keys, fmt.Sprintf("%040d", i))
+	}
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: keys,
+		Budget:  &prowl.BudgetPolicy{Reserve: map[int]int{prowl.PrioHigh: 50}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(prio int) (prowl.Result, error) {
+		return client.Send(context.Background(), prowl.Notification{Priority: prio, Event: "Event"})
+	}
+
+	//the 3 requests for 12 keys would eat into the reserve
+	mock.SetRemaining(55)
+	if result, err := send(prowl.PrioNormal); err != nil || result.Remaining != 52 {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+	requests := len(mock.Requests())
+	if result, err := send(prowl.PrioNormal); !errors.Is(err, prowl.ErrReserved) || result.Status != prowl.StatusDropped {
+		t.Errorf("notification should have been dropped %+v: %v", result, err)
+	}
+	if len(mock.Requests()) != requests {
+		t.Error("dropped notification should not be sent")
+	}
+	if _, err := send(prowl.PrioHigh); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBudgetQueue(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	dir, err := ioutil.TempDir("", "prowlgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Outbox:  &prowl.OutboxConfig{Path: filepath.Join(dir, "outbox"), RetryInterval: 50 * time.Millisecond},
+		Budget:  &prowl.BudgetPolicy{Reserve: map[int]int{prowl.PrioHigh: 50}, Queue: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	mock.SetRemaining(51)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "sent"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := client.Send(context.Background(), prowl.Notification{Priority: prowl.PrioNormal, Event: "Event", Description: "queued"})
+	if !errors.Is(err, prowl.ErrQueued) || !errors.Is(err, prowl.ErrReserved) || result.Status != prowl.StatusQueued {
+		t.Errorf("notification should have been queued %+v: %v", result, err)
+	}
+
+	//important notifications overtake the queued ones
+	result, err = client.Send(context.Background(), prowl.Notification{Priority: prowl.PrioEmergency, Event: "Event", Description: "emergency"})
+	if err != nil || result.Status != prowl.StatusSent {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+	//notifications of the same priority wait behind the queued ones
+	if _, err := client.Add(prowl.PrioNormal, "Event", "also queued"); !errors.Is(err, prowl.ErrQueued) {
+		t.Errorf("notification should have been queued: %v", err)
+	}
+	if stats := client.OutboxStats(); stats.Pending != 2 {
+		t.Errorf("unexpected outbox stats %+v", stats)
+	}
+
+	//the limit is reset: the queued notifications are delivered
+	mock.SetRemaining(prowltest.DefaultRemaining)
+	mock.SetResetDate(time.Now().Add(-1 * time.Minute))
+	if _, err := client.Add(prowl.PrioHigh, "Event", "reset"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return client.OutboxStats().Pending == 0 })
+	descr := addedDescriptions()
+	if fmt.Sprint(descr) != "[sent emergency reset queued also queued]" {
+		t.Errorf("unexpected notifications %v", descr)
+	}
+}
+
+func ExampleBudgetPolicy() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		Application: "prowlgo Example",
+		//Keep the last 50 api calls of the hour for real trouble
+		Budget: &prowl.BudgetPolicy{Reserve: map[int]int{prowl.PrioHigh: 50}},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	result, err := client.Send(context.Background(), prowl.Notification{
+		Priority:    prowl.PrioVeryLow,
+		Event:       "Debug",
+		Description: "cache miss",
+	})
+	if errors.Is(err, prowl.ErrReserved) {
+		fmt.Println("not important enough right now")
+	} else if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(result.Status)
+
+	//output:
+	//sent
+}
@@ -1,6 +1,10 @@
 package prowlgo
 
-import "log"
+import (
+	"log"
+	"net/http"
+	"time"
+)
 
 // Builder is used to create a new prowl client -- if you like the
 // builder pattern better than providing a config struct to the NewClient function.
@@ -15,7 +19,7 @@ func NewBuilder() *Builder {
 }
 
 // AddAPIKey adds the provided api key to the list of api keys that the client will use. Needs to be
-//called at least once when your client needs to make Add requests.
+// called at least once when your client needs to make Add requests.
 func (bld *Builder) AddAPIKey(apiKey string) *Builder {
 	//no checking for duplicates here. NewClient() will take care of this later on.
 	bld.config.APIKeys = append(bld.config.APIKeys, apiKey)
@@ -30,6 +34,42 @@ func (bld *Builder) AddAllAPIKeys(apiKeys []string) *Builder {
 	return bld
 }
 
+// AddRecipient adds a named recipient to the client (see Config.Recipients).
+func (bld *Builder) AddRecipient(name string, apiKey string) *Builder {
+	if bld.config.Recipients == nil {
+		bld.config.Recipients = make(map[string]string)
+	}
+	bld.config.Recipients[name] = apiKey
+	return bld
+}
+
+// AddGroupMember adds a recipient to a group of the client (see Config.Groups).
+func (bld *Builder) AddGroupMember(group string, recipient string) *Builder {
+	if bld.config.Groups == nil {
+		bld.config.Groups = make(map[string][]string)
+	}
+	bld.config.Groups[group] = append(bld.config.Groups[group], recipient)
+	return bld
+}
+
+// Subscribe subscribes a recipient or a group to a topic (see Config.Topics).
+func (bld *Builder) Subscribe(topic string, name string) *Builder {
+	if bld.config.Topics == nil {
+		bld.config.Topics = make(map[string][]string)
+	}
+	bld.config.Topics[topic] = append(bld.config.Topics[topic], name)
+	return bld
+}
+
+// SetRotation adds an on-call rotation to the client (see Config.Rotations).
+func (bld *Builder) SetRotation(name string, rotation Rotation) *Builder {
+	if bld.config.Rotations == nil {
+		bld.config.Rotations = make(map[string]Rotation)
+	}
+	bld.config.Rotations[name] = rotation
+	return bld
+}
+
 // SetToken defines the token for the client that will be built using this builder.
 // Needs to be set if this client should continue the process of retrieving a new api key
 // after the user approved the request.
@@ -69,6 +109,95 @@ func (bld *Builder) SetToProwlLabel(label string) *Builder {
 	return bld
 }
 
+// SetLogTimeout defines how long Client.Log() waits for the prowl server before the
+// request is aborted and a timeout is reported in the log.
+func (bld *Builder) SetLogTimeout(timeout time.Duration) *Builder {
+	bld.config.LogTimeout = timeout
+	return bld
+}
+
+// SetBaseURL defines the URL of the prowl api the client will talk to. Only needed if the
+// client should not talk to the prowl server directly.
+func (bld *Builder) SetBaseURL(baseURL string) *Builder {
+	bld.config.BaseURL = baseURL
+	return bld
+}
+
+// SetHTTPClient defines the http client that is used for all requests to the prowl server.
+func (bld *Builder) SetHTTPClient(httpClient *http.Client) *Builder {
+	bld.config.HTTPClient = httpClient
+	return bld
+}
+
+// SetTransport defines the http transport that is used for all requests to the prowl server.
+// Use either this or SetHTTPClient() but not both.
+func (bld *Builder) SetTransport(transport http.RoundTripper) *Builder {
+	bld.config.Transport = transport
+	return bld
+}
+
+// SetRetryPolicy defines how failed requests to the prowl server are repeated.
+func (bld *Builder) SetRetryPolicy(policy RetryPolicy) *Builder {
+	bld.config.Retry = &policy
+	return bld
+}
+
+// SetOutbox enables the outbox of the client. See OutboxConfig.
+func (bld *Builder) SetOutbox(outbox OutboxConfig) *Builder {
+	bld.config.Outbox = &outbox
+	return bld
+}
+
+// SetEscalation enables the escalation of important notifications. See EscalationConfig.
+func (bld *Builder) SetEscalation(escalation EscalationConfig) *Builder {
+	bld.config.Escalation = &escalation
+	return bld
+}
+
+// SetBudget reserves api calls for important notifications. See BudgetPolicy.
+func (bld *Builder) SetBudget(budget BudgetPolicy) *Builder {
+	bld.config.Budget = &budget
+	return bld
+}
+
+// SetPacing spreads the remaining api calls over the time until the limit is reset.
+// See PacingConfig.
+func (bld *Builder) SetPacing(pacing PacingConfig) *Builder {
+	bld.config.Pacing = &pacing
+	return bld
+}
+
+// AddQuietHours holds back notifications of low priority during the quiet hours. See
+// QuietHours.
+func (bld *Builder) AddQuietHours(quietHours QuietHours) *Builder {
+	bld.config.QuietHours = append(bld.config.QuietHours, quietHours)
+	return bld
+}
+
+// SetSilenceFile persists the silences of the client in the file. See Silence.
+func (bld *Builder) SetSilenceFile(path string) *Builder {
+	bld.config.SilenceFile = path
+	return bld
+}
+
+// SetSchedule enables the scheduler of the client. See ScheduleConfig.
+func (bld *Builder) SetSchedule(schedule ScheduleConfig) *Builder {
+	bld.config.Schedule = &schedule
+	return bld
+}
+
+// SetThrottle suppresses repeated notifications. See ThrottleConfig.
+func (bld *Builder) SetThrottle(throttle ThrottleConfig) *Builder {
+	bld.config.Throttle = &throttle
+	return bld
+}
+
+// SetDigest combines notifications of low priority into summaries. See DigestConfig.
+func (bld *Builder) SetDigest(digest DigestConfig) *Builder {
+	bld.config.Digest = &digest
+	return bld
+}
+
 // Build creates and returns the new prowl client. If any of the previous calls provided
 // illegal client configuration this call will raise the respective error.
 func (bld *Builder) Build() (client *Client, err error) {
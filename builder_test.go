@@ -5,6 +5,7 @@ import (
 	prowl "github.com/tweithoener/prowlgo"
 	"log"
 	"os"
+	"time"
 )
 
 func ExampleBuilder_simple() {
@@ -53,6 +54,7 @@ func ExampleBuilder_more() {
 		SetApplication("prowlgo Test").
 		SetToProwlLabel(toProwlLabel).
 		SetLogger(log.New(os.Stdout, "", 0)).
+		SetLogTimeout(5 * time.Second).
 		Build()
 	if err != nil {
 		fmt.Println(err)
@@ -77,6 +79,9 @@ func ExampleBuilder_more() {
 	if *client.Config().ToProwlLabel == toProwlLabel {
 		fmt.Println("toProwlLabel is ok")
 	}
+	if client.Config().LogTimeout == 5*time.Second {
+		fmt.Println("log timeout is ok")
+	}
 
 	//output:
 	//api key is ok
@@ -84,4 +89,5 @@ func ExampleBuilder_more() {
 	//provider key is ok
 	//token is ok
 	//toProwlLabel is ok
+	//log timeout is ok
 }
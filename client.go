@@ -4,17 +4,25 @@
 //
 // To use this package create a Client instance either by using NewClient or by creating the instance
 // using the Builder.
+//
+// Errors returned by the client can be inspected using errors.Is with the Err* sentinel errors
+// and errors.As with *APIError (errors reported by the prowl server) and *FieldError (illegal
+// arguments and configuration).
 package prowlgo
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -32,11 +40,11 @@ const (
 )
 
 const (
-	prowlBase         = "https://api.prowlapp.com/publicapi/"
-	addURL            = prowlBase + "add"
-	verifyURL         = prowlBase + "verify"
-	retrieveTokenURL  = prowlBase + "retrieve/token"
-	retrieveAPIKeyURL = prowlBase + "retrieve/apikey"
+	prowlBase          = "https://api.prowlapp.com/publicapi/"
+	addPath            = "add"
+	verifyPath         = "verify"
+	retrieveTokenPath  = "retrieve/token"
+	retrieveAPIKeyPath = "retrieve/apikey"
 
 	defaultTimeout = 30 * time.Second
 	waitSync       = -1 * time.Second
@@ -44,9 +52,6 @@ const (
 	//DefaultToProwlLabel is the default label that is attached to log lines that have also been
 	//sent to prowl (see Log() function).
 	DefaultToProwlLabel = "(copied to prowl)"
-
-	enter = true
-	leave = false
 )
 
 // Client represents the prowl client which is used
@@ -54,19 +59,40 @@ const (
 // http api which is described here http://www.prowlapp.com/api.php
 //
 // With the client you can do the following
-//  - Send messages to iOS devices (see Add)
-//  - Retrieve a new api key for your application. The process here is
-//    RetrieveToken, present approve URL to used and let them approve your request on the prowl website,
-//    then RetrieveAPIKey and work with the new key.
-//  - Write to the log and inparallel notify your iOS device (see Log and LogSync)
+//   - Send messages to iOS devices (see Add)
+//   - Retrieve a new api key for your application. The process here is
+//     RetrieveToken, present approve URL to used and let them approve your request on the prowl website,
+//     then RetrieveAPIKey and work with the new key.
+//   - Write to the log and inparallel notify your iOS device (see Log and LogSync)
+//
+// A Client is safe for concurrent use by multiple goroutines.
 type Client struct {
-	config       Config
+	config     Config
+	httpClient *http.Client
+
+	//mu guards all of the following fields as well as config.Token and config.APIKeys.
+	//It is never held while waiting for the prowl server.
+	//apiKeys maps all api keys to their state. Keys rejected by the prowl server are
+	//quarantined (false) and excluded from further requests.
+	mu           sync.Mutex
 	apiKeys      map[string]bool
 	apiKeysDirty bool
-	lock         chan bool
-	unauthorized bool
+	recipients   map[string]string
+	groups       membership
+	topics       membership
+	rotations    map[string]*rotation
 	remaining    int
 	reset        time.Time
+	pacer        *pacer
+	throttle     *throttle
+	quietHours   []*quietRule
+
+	outbox    *outbox
+	escalator *escalator
+	digest    *digest
+	deferrals *deferrals
+	silences  *silences
+	scheduler *scheduler
 }
 
 // Config can be used to create a new Client. It might be handy if you need to
@@ -79,6 +105,25 @@ type Config struct {
 	//when the Config() getter is called.
 	APIKeys []string
 
+	//Recipients maps recipient names (e.g. "alice" or "ops-phone") to api keys. Use AddTo to
+	//send a notification to some of the recipients only. The keys of the recipients belong to
+	//the keys of the client, i.e. notifications sent by Add also go to the recipients.
+	Recipients map[string]string
+
+	//Groups maps group names (e.g. "oncall") to the names of their members. Members must be
+	//recipients. Group names can be used wherever recipient names are accepted.
+	Groups map[string][]string
+
+	//Topics maps topics (e.g. "db", "billing" or "deploys") to the names of the recipients and
+	//groups that subscribed to the topic. Use AddToTopic to send a notification to the
+	//subscribers of a topic. Subscriptions can be changed at runtime using Subscribe and
+	//Unsubscribe.
+	Topics map[string][]string
+
+	//Rotations maps names of on-call rotations (e.g. "primary") to their schedule. The
+	//name of a rotation can be used wherever recipient names are accepted. See Rotation.
+	Rotations map[string]Rotation
+
 	//ProviderKey is the provider key that is used in RetrieveToken and RetrieveAPIKey calls.
 	//It must be defined for these calls. It is optional for calls to Add. Here it might
 	//be usefull if prowl granted a higher api limit to the provider key.
@@ -102,6 +147,62 @@ type Config struct {
 	//this label to the message that goes to the log to indicate that the message was also sent
 	//to the prowl app.
 	ToProwlLabel *string
+
+	//LogTimeout is the time Client.Log() waits for the prowl server before it gives up and
+	//reports a timeout in the log. Defaults to 30 seconds if left empty.
+	LogTimeout time.Duration
+
+	//BaseURL is the URL of the prowl api the client talks to. All request paths (add, verify, ...)
+	//are resolved relative to this URL. Defaults to https://api.prowlapp.com/publicapi/ and
+	//only needs to be changed if you want to talk to a proxy or a stand-in prowl server.
+	BaseURL string
+
+	//HTTPClient is the http client used for all requests to the prowl server. Use it to
+	//configure proxies, custom TLS roots or timeouts for this client only.
+	//http.DefaultClient will be used if neither HTTPClient nor Transport is defined.
+	HTTPClient *http.Client `json:"-"`
+
+	//Transport is a shorthand for an HTTPClient that only differs from the default in its
+	//transport. It must not be defined together with HTTPClient.
+	Transport http.RoundTripper `json:"-"`
+
+	//Retry defines if and how failed requests are repeated. Requests are sent only once
+	//if no retry policy is defined.
+	Retry *RetryPolicy
+
+	//Outbox enables the on-disk outbox of the client. Notifications that can't be delivered
+	//because the prowl server is unreachable or the api call limit is spent are kept in the
+	//outbox and delivered later on. See OutboxConfig.
+	Outbox *OutboxConfig
+
+	//Escalation makes sure notifications with PrioHigh and PrioEmergency are acknowledged.
+	//See EscalationConfig.
+	Escalation *EscalationConfig
+
+	//Budget reserves api calls for important notifications. See BudgetPolicy.
+	Budget *BudgetPolicy
+
+	//Pacing spreads the remaining api calls over the time until the limit is reset.
+	//See PacingConfig.
+	Pacing *PacingConfig
+
+	//Digest combines notifications of low priority into a summary sent every few minutes.
+	//See DigestConfig.
+	Digest *DigestConfig
+
+	//Throttle suppresses repeated notifications. See ThrottleConfig.
+	Throttle *ThrottleConfig
+
+	//QuietHours hold back notifications of low priority while the recipients don't want to be
+	//disturbed. See QuietHours.
+	QuietHours []QuietHours
+
+	//SilenceFile is the file the silences of the client are persisted in. Silences are kept in
+	//memory only if it is empty. See Silence.
+	SilenceFile string
+
+	//Schedule enables SendAt, SendAfter and Remind. See ScheduleConfig.
+	Schedule *ScheduleConfig
 }
 
 // Response represents the prowl server responses.
@@ -143,25 +244,117 @@ func NewClient(config Config) (clt *Client, err error) {
 		config.APIKeys = make([]string, 0)
 	}
 	if len(config.ProviderKey) != 40 && len(config.ProviderKey) != 0 {
-		return nil, fmt.Errorf("provider key must either be 40 chars long or undefined")
+		return nil, newFieldError("ProviderKey", "provider key must either be 40 chars long or undefined")
 	}
 	if len(config.Token) != 40 && len(config.Token) != 0 {
-		return nil, fmt.Errorf("token must either be 40 chars long or undefined")
+		return nil, newFieldError("Token", "token must either be 40 chars long or undefined")
 	}
 	if len(config.Application) > 256 {
-		return nil, fmt.Errorf("application must not exceed 256 chars in length")
+		return nil, newFieldError("Application", "application must not exceed 256 chars in length")
+	}
+	if config.LogTimeout < 0 {
+		return nil, newFieldError("LogTimeout", "log timeout must not be negative")
+	}
+	if config.HTTPClient != nil && config.Transport != nil {
+		return nil, newFieldError("Transport", "http client and transport must not be defined both")
+	}
+	if len(config.BaseURL) != 0 {
+		u, err := url.Parse(config.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, newFieldError("BaseURL", "base url must either be an absolute http(s) url or undefined")
+		}
+		if !strings.HasSuffix(config.BaseURL, "/") {
+			config.BaseURL += "/"
+		}
+	}
+
+	if config.Retry != nil {
+		if err := config.Retry.validate(); err != nil {
+			return nil, err
+		}
+		cpy := *config.Retry
+		config.Retry = &cpy
+	}
+
+	if config.Outbox != nil {
+		if err := config.Outbox.validate(); err != nil {
+			return nil, err
+		}
+		cpy := *config.Outbox
+		config.Outbox = &cpy
+	}
+
+	if config.Budget != nil {
+		cpy := *config.Budget
+		if err := cpy.validate(); err != nil {
+			return nil, err
+		}
+		if cpy.Queue && config.Outbox == nil {
+			return nil, newFieldError("Budget", "queueing requires an outbox")
+		}
+		config.Budget = &cpy
+	}
+
+	if config.Pacing != nil {
+		cpy := *config.Pacing
+		if err := cpy.validate(); err != nil {
+			return nil, err
+		}
+		config.Pacing = &cpy
+	}
+
+	if config.Digest != nil {
+		cpy := *config.Digest
+		if err := cpy.validate(); err != nil {
+			return nil, err
+		}
+		config.Digest = &cpy
+	}
+
+	if config.Throttle != nil {
+		cpy := *config.Throttle
+		if err := cpy.validate(); err != nil {
+			return nil, err
+		}
+		config.Throttle = &cpy
+	}
+
+	if config.Escalation != nil {
+		cpy := *config.Escalation
+		if err := cpy.validate(); err != nil {
+			return nil, err
+		}
+		config.Escalation = &cpy
+	}
+
+	if config.Schedule != nil {
+		cpy := *config.Schedule
+		if err := cpy.validate(); err != nil {
+			return nil, err
+		}
+		config.Schedule = &cpy
 	}
 
 	apiKeys := make(map[string]bool)
 	for _, key := range config.APIKeys {
 		if len(key) != 40 {
-			return nil, fmt.Errorf("api key must either be 40 chars long or undefined")
+			return nil, newFieldError("APIKeys", "api key must either be 40 chars long or undefined")
 		}
 		if !apiKeys[key] {
 			apiKeys[key] = true
 		}
 	}
 
+	recipients := make(map[string]string, len(config.Recipients))
+	for name, key := range config.Recipients {
+		if err := validateRecipient(name, key); err != nil {
+			return nil, err
+		}
+		recipients[name] = key
+		apiKeys[key] = true
+	}
+	config.Recipients = nil
+
 	if config.Logger == nil {
 		config.Logger = log.New(os.Stderr, "", log.LstdFlags)
 	}
@@ -169,16 +362,100 @@ func NewClient(config Config) (clt *Client, err error) {
 		cpy := DefaultToProwlLabel
 		config.ToProwlLabel = &cpy
 	}
+	if config.LogTimeout == 0 {
+		config.LogTimeout = defaultTimeout
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+		if config.Transport != nil {
+			httpClient = &http.Client{Transport: config.Transport}
+		}
+	}
 
-	return &Client{
+	clt = &Client{
 		config:       config,
+		httpClient:   httpClient,
 		apiKeys:      apiKeys,
 		apiKeysDirty: len(apiKeys) != len(config.APIKeys),
-		lock:         make(chan bool, 1),
-		unauthorized: false,
+		recipients:   recipients,
+		groups:       make(membership),
+		topics:       make(membership),
+		rotations:    make(map[string]*rotation),
 		remaining:    1000,
 		reset:        time.Now().Add(1 * time.Hour),
-	}, nil
+	}
+
+	for name, r := range config.Rotations {
+		if err := clt.setRotation(name, r); err != nil {
+			return nil, err
+		}
+	}
+	if clt.silences, err = openSilences(config.SilenceFile); err != nil {
+		return nil, err
+	}
+	for _, qh := range config.QuietHours {
+		if err := clt.addQuietHours(qh); err != nil {
+			return nil, err
+		}
+	}
+	for group, members := range config.Groups {
+		for _, name := range members {
+			if err := clt.checkGroupMember(group, name); err != nil {
+				return nil, err
+			}
+			clt.groups.add(group, name)
+		}
+	}
+	for topic, names := range config.Topics {
+		for _, name := range names {
+			if err := clt.checkSubscriber(topic, name); err != nil {
+				return nil, err
+			}
+			clt.topics.add(topic, name)
+		}
+	}
+	clt.config.Groups, clt.config.Topics, clt.config.Rotations = nil, nil, nil
+
+	if config.Escalation != nil {
+		for _, tier := range config.Escalation.Tiers {
+			for _, name := range tier {
+				if !clt.knownTarget(name) {
+					return nil, newFieldError("Escalation", "%s of escalation tier is neither a recipient nor a group nor a rotation", name)
+				}
+			}
+		}
+		clt.escalator = newEscalator(clt, *config.Escalation)
+	}
+
+	if config.Pacing != nil {
+		clt.pacer = newPacer(*config.Pacing)
+	}
+	if config.Throttle != nil {
+		clt.throttle = newThrottle(*config.Throttle)
+	}
+
+	if config.Outbox != nil {
+		if clt.outbox, err = openOutbox(clt, *config.Outbox); err != nil {
+			return nil, err
+		}
+	}
+	if config.Digest != nil {
+		clt.digest = newDigest(clt, *config.Digest)
+	}
+	if len(clt.quietHours) > 0 {
+		clt.deferrals = newDeferrals(clt)
+	}
+	//the scheduler might send right away, so it comes last
+	if config.Schedule != nil {
+		if clt.scheduler, err = openScheduler(clt, *config.Schedule); err != nil {
+			clt.Close()
+			return nil, err
+		}
+	}
+
+	return clt, nil
 }
 
 // Add adds an event to the prowl queue which will be delivered to the client app
@@ -188,7 +465,14 @@ func NewClient(config Config) (clt *Client, err error) {
 // the prowl server or in case of illegal arguments. If the request was successful
 // the number of remaining prowl api requests (messages that can be sent) will be returned.
 func (clt *Client) Add(priority int, event string, description string) (remaining int, err error) {
-	return clt.AddWithURL(priority, event, description, "", false)
+	return clt.AddWithURLContext(context.Background(), priority, event, description, "", false)
+}
+
+// AddContext is the same as Add() but the request to the prowl server is bound to
+// the provided context. The request is aborted as soon as the context is canceled or
+// its deadline is exceeded.
+func (clt *Client) AddContext(ctx context.Context, priority int, event string, description string) (remaining int, err error) {
+	return clt.AddWithURLContext(ctx, priority, event, description, "", false)
 }
 
 // AddWithURL is the same as Add() with an additional URL argument. This URL will be presented to the
@@ -198,69 +482,20 @@ func (clt *Client) Add(priority int, event string, description string) (remainin
 // As of the writing of this code in such a case the prowl app displays a
 // little (i) next to the message that the user can tap to open the URL.
 func (clt *Client) AddWithURL(priority int, event string, description string, withURL string, appendURL bool) (remaining int, err error) {
-	if clt.unauthorized {
-		return clt.remaining, fmt.Errorf("api key(s) are known to be invalid")
-	}
-	if len(clt.apiKeys) == 0 {
-		return clt.remaining, fmt.Errorf("a valid api key is required for add operation")
-	}
-	if priority < -2 || priority > 2 {
-		return clt.remaining, fmt.Errorf("priority argument must be in the range -2..2")
-	}
-	if len(event) > 1024 {
-		return clt.remaining, fmt.Errorf("event argument must not exceed 1024 chars")
-	}
-	if len(description) > 10000 {
-		return clt.remaining, fmt.Errorf("description argument must not exceed 10000 chars")
-	}
-	if len(withURL) > 256 {
-		return clt.remaining, fmt.Errorf("withURL argument must not exceed 512 chars")
-	}
-	if clt.remaining <= 0 && clt.reset.After(time.Now()) {
-		return clt.remaining, fmt.Errorf("api requests spent; come back after %s", clt.reset)
-	}
-
-	event = strings.TrimSpace(event)
-	description = strings.TrimSpace(description)
-	withURL = strings.TrimSpace(withURL)
-
-	if len(withURL) > 0 {
-		if appendURL {
-			if len(description)+len(withURL)+4 > 10000 {
-				description = strings.TrimSpace(description[0:10000-len(withURL)-4]) + "..."
-			}
-			description = description + " " + withURL
-		}
-	}
+	return clt.AddWithURLContext(context.Background(), priority, event, description, withURL, appendURL)
+}
 
-	resp, err := http.PostForm(addURL, url.Values{
-		"apikey":      {clt.makeAPIKeyRequestArgument()},
-		"providerkey": {clt.config.ProviderKey},
-		"priority":    {fmt.Sprintf("%d", priority)},
-		"application": {clt.config.Application},
-		"event":       {event},
-		"description": {description},
-		"url":         {withURL},
+// AddWithURLContext is the same as AddWithURL() but the request to the prowl server is bound to
+// the provided context.
+func (clt *Client) AddWithURLContext(ctx context.Context, priority int, event string, description string, withURL string, appendURL bool) (remaining int, err error) {
+	result, err := clt.Send(ctx, Notification{
+		Priority:    priority,
+		Event:       event,
+		Description: description,
+		URL:         withURL,
+		AppendURL:   appendURL,
 	})
-	response, err := clt.handleResponse(resp, err)
-
-	if response.Error.Code == 401 {
-		clt.unauthorized = true
-	}
-	if response.Error.Code == 406 {
-		clt.remaining = 0
-	}
-
-	if err != nil {
-		return clt.remaining, fmt.Errorf("add request to prowl server failed: %s", err)
-	}
-
-	if response.Success.XMLName.Local != "" {
-		clt.reset = time.Unix(response.Success.Resetdate, 0)
-		clt.remaining = response.Success.Remaining
-	}
-
-	return clt.remaining, nil
+	return result.Remaining, err
 }
 
 // Verify verifys the validity of the provided api key.
@@ -270,31 +505,44 @@ func (clt *Client) AddWithURL(priority int, event string, description string, wi
 // If the key is not valid it will return an error. If the key is OK
 // the number of remaining api calls is returned.
 func (clt *Client) Verify(apiKey string) (remaining int, err error) {
-	remaining = clt.remaining
+	return clt.VerifyContext(context.Background(), apiKey)
+}
+
+// VerifyContext is the same as Verify() but the request to the prowl server is bound to
+// the provided context.
+func (clt *Client) VerifyContext(ctx context.Context, apiKey string) (remaining int, err error) {
+	return clt.verify(ctx, apiKey, true)
+}
+
+// verify verifies the api key. The provider key of the client is sent along if withProvider
+// is true.
+func (clt *Client) verify(ctx context.Context, apiKey string, withProvider bool) (remaining int, err error) {
+	remaining = clt.remainingCalls()
 	if len(apiKey) != 40 {
-		err = fmt.Errorf("apiKey argument must be exactly 40 chars long")
+		err = newFieldError("apiKey", "apiKey argument must be exactly 40 chars long")
 		return
 	}
 
-	u, err := url.Parse(verifyURL)
+	u, err := url.Parse(clt.endpoint(verifyPath))
 	if err != nil {
 		panic("verify url can not be parsed")
 	}
 
 	q := u.Query()
 	q.Set("apikey", apiKey)
-	if len(clt.config.ProviderKey) == 40 {
+	if withProvider && len(clt.config.ProviderKey) == 40 {
 		q.Set("providerkey", clt.config.ProviderKey)
 	}
 	u.RawQuery = q.Encode()
 
-	resp, err := http.Get(u.String())
-	_, err = clt.handleResponse(resp, err)
+	_, err = clt.request(ctx, "verify", func() (*http.Response, error) {
+		return clt.get(ctx, u.String())
+	})
 	if err != nil {
-		return clt.remaining, fmt.Errorf("verify request to prowl server failed: %s", err)
+		return clt.remainingCalls(), fmt.Errorf("verify request to prowl server failed: %w", err)
 	}
 
-	return clt.remaining, nil
+	return clt.remainingCalls(), nil
 }
 
 // RetrieveToken retrieves a token from the prowl server. This token has to
@@ -309,12 +557,18 @@ func (clt *Client) Verify(apiKey string) (remaining int, err error) {
 // the persisted config later on. The config also contains the token (together with
 // the provider key that you also will need during RetrieveAPIKey)
 func (clt *Client) RetrieveToken() (approveURL string, err error) {
+	return clt.RetrieveTokenContext(context.Background())
+}
+
+// RetrieveTokenContext is the same as RetrieveToken() but the request to the prowl server
+// is bound to the provided context.
+func (clt *Client) RetrieveTokenContext(ctx context.Context) (approveURL string, err error) {
 	if len(clt.config.ProviderKey) != 40 {
-		err = fmt.Errorf("provider key is required for retrieve token operation")
+		err = newFieldError("ProviderKey", "provider key is required for retrieve token operation")
 		return
 	}
 
-	u, err := url.Parse(retrieveTokenURL)
+	u, err := url.Parse(clt.endpoint(retrieveTokenPath))
 	if err != nil {
 		panic("retrieve token url can not be parsed")
 	}
@@ -323,15 +577,18 @@ func (clt *Client) RetrieveToken() (approveURL string, err error) {
 	q.Set("providerkey", clt.config.ProviderKey)
 	u.RawQuery = q.Encode()
 
-	resp, err := http.Get(u.String())
-	response, err := clt.handleResponse(resp, err)
+	response, err := clt.request(ctx, "retrieve token", func() (*http.Response, error) {
+		return clt.get(ctx, u.String())
+	})
 	if err != nil {
-		err = fmt.Errorf("retrieve token request to prowl server failed: %s", err)
+		err = fmt.Errorf("retrieve token request to prowl server failed: %w", err)
 		return
 	}
 
 	approveURL = response.Retrieve.URL
+	clt.mu.Lock()
 	clt.config.Token = response.Retrieve.Token
+	clt.mu.Unlock()
 
 	return
 }
@@ -339,91 +596,179 @@ func (clt *Client) RetrieveToken() (approveURL string, err error) {
 // RetrieveAPIKey retrieves a new api key from the prowl server. This call requires
 // that this client is configured with a valid provider key and a vaild token.
 //
-//For an Example see Client.RetrieveToken
+// For an Example see Client.RetrieveToken
 func (clt *Client) RetrieveAPIKey() (apiKey string, err error) {
-	if len(clt.config.Token) != 40 {
-		err = fmt.Errorf("token is required for retrieve token operation")
+	return clt.RetrieveAPIKeyContext(context.Background())
+}
+
+// RetrieveAPIKeyContext is the same as RetrieveAPIKey() but the request to the prowl server
+// is bound to the provided context.
+func (clt *Client) RetrieveAPIKeyContext(ctx context.Context) (apiKey string, err error) {
+	clt.mu.Lock()
+	token := clt.config.Token
+	clt.mu.Unlock()
+
+	if len(token) != 40 {
+		err = newFieldError("Token", "token is required for retrieve api key operation")
 		return
 	}
 	if len(clt.config.ProviderKey) != 40 {
-		err = fmt.Errorf("provider key is required for retrieve token operation")
+		err = newFieldError("ProviderKey", "provider key is required for retrieve api key operation")
 		return
 	}
 
-	u, err := url.Parse(retrieveAPIKeyURL)
+	u, err := url.Parse(clt.endpoint(retrieveAPIKeyPath))
 	if err != nil {
 		panic("retrieve api key url can not be parsed")
 	}
 
 	q := u.Query()
 	q.Set("providerkey", clt.config.ProviderKey)
-	q.Set("token", clt.config.Token)
+	q.Set("token", token)
 	u.RawQuery = q.Encode()
 
-	resp, err := http.Get(u.String())
-	response, err := clt.handleResponse(resp, err)
+	response, err := clt.request(ctx, "retrieve api key", func() (*http.Response, error) {
+		return clt.get(ctx, u.String())
+	})
 	if err != nil {
-		err = fmt.Errorf("retrieve api key request to prowl server failed: %s", err)
+		err = fmt.Errorf("retrieve api key request to prowl server failed: %w", err)
 		return
 	}
 
 	apiKey = response.Retrieve.APIKey
+	clt.mu.Lock()
 	clt.apiKeys[apiKey] = true
 	clt.apiKeysDirty = true
-	clt.unauthorized = false
+	clt.mu.Unlock()
 
 	return
 }
 
-func (clt *Client) makeAPIKeyRequestArgument() (req string) {
-	i := 0
-	for key := range clt.apiKeys {
-		req += key
-		if i < len(clt.apiKeys)-1 {
-			req += ","
+// WaitForAPIKey calls RetrieveAPIKey every interval until the user approved the token
+// retrieved by RetrieveToken and returns the new api key. While the token is not yet
+// approved (prowl error code 409) it keeps on waiting. Any other error is returned right
+// away. If ctx is done before the token was approved an *ApprovalTimeoutError is returned.
+//
+// For an Example see Client.RetrieveToken
+func (clt *Client) WaitForAPIKey(ctx context.Context, interval time.Duration) (apiKey string, err error) {
+	if interval <= 0 {
+		err = newFieldError("interval", "interval argument must be positive")
+		return
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for attempts := 0; ; {
+		select {
+		case <-ctx.Done():
+			return "", &ApprovalTimeoutError{Attempts: attempts, Err: ctx.Err()}
+		case <-timer.C:
 		}
-		i++
+
+		attempts++
+		apiKey, err = clt.RetrieveAPIKeyContext(ctx)
+		if err == nil {
+			return
+		}
+		if ctx.Err() != nil {
+			return "", &ApprovalTimeoutError{Attempts: attempts, Err: ctx.Err()}
+		}
+		if !errors.Is(err, ErrTokenNotApproved) {
+			return
+		}
+		timer.Reset(interval)
 	}
+}
+
+func (clt *Client) postForm(ctx context.Context, u string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return clt.httpClient.Do(req)
+}
+
+func (clt *Client) get(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return clt.httpClient.Do(req)
+}
+
+func (clt *Client) endpoint(path string) string {
+	if len(clt.config.BaseURL) == 0 {
+		return prowlBase + path
+	}
+	return clt.config.BaseURL + path
+}
+
+// activeAPIKeys returns the api keys which are not quarantined in a stable order.
+// Must be called with clt.mu held.
+func (clt *Client) activeAPIKeys() (keys []string) {
+	for key, active := range clt.apiKeys {
+		if active {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
 	return
 }
 
-func (clt *Client) handleResponse(resp *http.Response, inerr error) (response Response, err error) {
+func (clt *Client) handleResponse(operation string, resp *http.Response, inerr error) (response Response, err error) {
 	if inerr != nil {
-		err = fmt.Errorf("HTTP request to prowl server failed: %s", inerr)
+		err = &transportError{err: inerr}
 		return
 	}
 
 	defer func() {
 		if cerr := resp.Body.Close(); cerr != nil {
-			cerr = fmt.Errorf("error closing HTTP response body: %s", cerr)
+			cerr = &transportError{err: fmt.Errorf("error closing HTTP response body: %w", cerr)}
 			if err == nil {
 				err = cerr
 			} else {
-				err = fmt.Errorf("%s. Followed by: %s", err, cerr)
+				err = fmt.Errorf("%w. Followed by: %s", err, cerr)
 			}
 		}
 	}()
 
 	buf, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		err = fmt.Errorf("can't read HTTP response body: %s", err)
+		err = &transportError{err: fmt.Errorf("can't read HTTP response body: %w", err)}
 		return
 	}
 
 	err = xml.Unmarshal(buf, &response)
 	if err != nil {
+		if resp.StatusCode >= 300 {
+			err = &APIError{
+				Operation:  operation,
+				Code:       resp.StatusCode,
+				Message:    http.StatusText(resp.StatusCode),
+				StatusCode: resp.StatusCode,
+			}
+			return
+		}
 		err = fmt.Errorf("can't unmarshal xml response from prowl server: %s", err)
 		return
 	}
 
 	if len(response.Error.XMLName.Local) != 0 {
-		err = fmt.Errorf("prowl returned error code %d: %s", response.Error.Code, response.Error.Message)
+		err = &APIError{
+			Operation:  operation,
+			Code:       response.Error.Code,
+			Message:    strings.TrimSpace(response.Error.Message),
+			StatusCode: resp.StatusCode,
+		}
 		return
 	}
 
 	if len(response.Success.XMLName.Local) != 0 {
+		clt.mu.Lock()
 		clt.reset = time.Unix(response.Success.Resetdate, 0)
 		clt.remaining = response.Success.Remaining
+		clt.mu.Unlock()
 	}
 
 	return
@@ -431,6 +776,7 @@ func (clt *Client) handleResponse(resp *http.Response, inerr error) (response Re
 
 // AddAPIKey will add a new API key to the list of keys held by this client.
 // All messages will then also be sent to the device associated to the newly added API key.
+// Adding a quarantined key (see QuarantinedAPIKeys) releases it from quarantine.
 // If the API key results from user input it might be a goor idea to call Verify() first to check
 // if the key is accepted by the server.
 // The function will return an error only in case of an illegal argument. Duplicates will be
@@ -438,11 +784,11 @@ func (clt *Client) handleResponse(resp *http.Response, inerr error) (response Re
 // not be detected in by this function.
 func (clt *Client) AddAPIKey(apiKey string) (err error) {
 	if len(apiKey) != 40 {
-		return fmt.Errorf("apiKey arguemtn must be exactly 40 chars long")
+		return newFieldError("apiKey", "apiKey argument must be exactly 40 chars long")
 	}
 
-	clt.mutex(enter)
-	defer clt.mutex(leave)
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
 
 	clt.apiKeys[apiKey] = true
 	clt.apiKeysDirty = true
@@ -451,44 +797,108 @@ func (clt *Client) AddAPIKey(apiKey string) (err error) {
 }
 
 // RemoveAPIKey removes the provided API key from the list of keys held by this client.
-// Messages will no longer be sent to the devices associated to this API key.
+// Messages will no longer be sent to the devices associated to this API key. Recipients
+// using this key are removed as well.
 // This function will only return an error in case of an illegal argument. If the provided
 // api key is not know to this client it will be handled silently.
 func (clt *Client) RemoveAPIKey(apiKey string) (err error) {
 	if len(apiKey) != 40 {
-		return fmt.Errorf("apiKey arguemtn must be exactly 40 chars long")
+		return newFieldError("apiKey", "apiKey argument must be exactly 40 chars long")
 	}
 
-	clt.mutex(enter)
-	defer clt.mutex(leave)
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
 
 	if _, ok := clt.apiKeys[apiKey]; !ok {
 		return
 	}
 	delete(clt.apiKeys, apiKey)
 	clt.apiKeysDirty = true
+	for name, key := range clt.recipients {
+		if key == apiKey {
+			delete(clt.recipients, name)
+			clt.forgetRecipient(name)
+		}
+	}
 
 	return
 }
 
-//Reset will return the reset time of the api call limit. This call will only return
-//reasonable values if a successful request to theserver was made before invoking this
-//method.
+// QuarantinedAPIKeys returns the api keys which have been rejected by the prowl server.
+// No more notifications are sent to these keys until they are added again using AddAPIKey.
+// Quarantined keys are still part of the config returned by Config.
+func (clt *Client) QuarantinedAPIKeys() (keys []string) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	for key, active := range clt.apiKeys {
+		if !active {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return
+}
+
+// Close stops all background activity of the client and releases its resources, e.g. the
+// outbox file. Notifications still pending in the outbox are kept on disk and will be
+// delivered by the next client using the same outbox. Open incidents (see EscalationConfig)
+// are not escalated any further. The summaries of the notifications held in the digest are
+// sent (see DigestConfig). Notifications deferred by quiet hours (see QuietHours) are not sent:
+// they are moved to the outbox, which delivers them when the quiet hours end, or dropped and
+// logged if the client has no outbox. Scheduled notifications are not sent any more (see
+// ScheduleConfig). Clients that have been configured with an outbox, escalation, a digest,
+// quiet hours or a scheduler must be closed. For other clients calling Close is optional.
+func (clt *Client) Close() error {
+	if clt.scheduler != nil {
+		clt.scheduler.close()
+	}
+	if clt.escalator != nil {
+		clt.escalator.shutdown()
+	}
+	var errs []error
+	if clt.digest != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		errs = append(errs, clt.digest.close(ctx))
+		cancel()
+	}
+	if clt.deferrals != nil {
+		errs = append(errs, clt.deferrals.close())
+	}
+	if clt.outbox != nil {
+		errs = append(errs, clt.outbox.close())
+	}
+	return errors.Join(errs...)
+}
+
+// Reset will return the reset time of the api call limit. This call will only return
+// reasonable values if a successful request to theserver was made before invoking this
+// method.
 func (clt *Client) Reset() time.Time {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
 	return clt.reset
 }
 
+func (clt *Client) remainingCalls() int {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	return clt.remaining
+}
+
 // Config returns the config of this client. This can be handy if you need to
 // persist the provider key and the token recieved from a call to RetrieveToken.
 // It might take some time until the user approves the request and another client instance
 // might be processing the following step (RetrieveAPIKey). This new instance can
 // easily be configured with the Config returned by this call.
 //
-//You might consider persisting it in a JSON serialization. This will omit the Config.Logger field
-//automatically.
+// You might consider persisting it in a JSON serialization. This will omit the Config.Logger field
+// automatically.
 func (clt *Client) Config() Config {
-	clt.mutex(enter)
-	defer clt.mutex(leave)
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
 
 	if clt.apiKeysDirty {
 		clt.config.APIKeys = make([]string, len(clt.apiKeys))
@@ -500,10 +910,22 @@ func (clt *Client) Config() Config {
 	}
 	clt.apiKeysDirty = false
 
-	return clt.config
+	config := clt.config
+	config.APIKeys = append([]string{}, clt.config.APIKeys...)
+	if len(clt.recipients) > 0 {
+		config.Recipients = make(map[string]string, len(clt.recipients))
+		for name, key := range clt.recipients {
+			config.Recipients[name] = key
+		}
+	}
+	config.Groups = clt.groups.config()
+	config.Topics = clt.topics.config()
+	config.Rotations = clt.rotationsConfig()
+	config.QuietHours = clt.quietHoursConfig()
+	return config
 }
 
-func (clt *Client) logWait(prio int, event string, description string, wait time.Duration) {
+func (clt *Client) logWait(ctx context.Context, prio int, event string, description string, wait time.Duration) {
 	clt.config.Logger.Println(event + ": " + description + " " + *clt.config.ToProwlLabel)
 
 	descrShort := description
@@ -516,44 +938,37 @@ func (clt *Client) logWait(prio int, event string, description string, wait time
 	}
 	msgShort := fmt.Sprintf("%s: %s", evShort, descrShort)
 
-	sent := make(chan bool, 1)
-	prowlf := func() {
-		if _, err := clt.Add(prio, event, description); err != nil {
-			clt.config.Logger.Printf("can't send prowl message (\"%s\") %s", msgShort, err)
-		}
-		sent <- true
+	if wait != waitSync {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wait)
+		defer cancel()
 	}
 
-	if wait == waitSync {
-		prowlf()
-	} else {
-		go prowlf()
-		select {
-		case <-time.After(wait):
+	if _, err := clt.AddContext(ctx, prio, event, description); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			clt.config.Logger.Printf("timeout while sending prowl message (\"%s\")", msgShort)
-		case <-sent:
+			return
 		}
+		clt.config.Logger.Printf("can't send prowl message (\"%s\") %s", msgShort, err)
 	}
 }
 
 // Log is shorthand for writing the event and description to the configured logger and
 // concurrently sending the message to the prowl server. The call will report an error
-// in the logs if sending to the server fails or times out.
+// in the logs if sending to the server fails or times out (see Config.LogTimeout).
 func (clt *Client) Log(prio int, event string, message string) {
-	go clt.logWait(prio, event, message, defaultTimeout)
+	go clt.logWait(context.Background(), prio, event, message, clt.config.LogTimeout)
 }
 
 // LogSync performs the same actions as Log but will block until the request to the prowl
 // server returns.
 func (clt *Client) LogSync(prio int, event string, message string) {
-	clt.logWait(prio, event, message, waitSync)
-
+	clt.logWait(context.Background(), prio, event, message, waitSync)
 }
 
-func (clt *Client) mutex(enter bool) {
-	if enter {
-		clt.lock <- true
-	} else {
-		<-clt.lock
-	}
+// LogSyncContext is the same as LogSync() but the request to the prowl server is bound to
+// the provided context. If the context is done before the server answers the error is
+// reported in the log.
+func (clt *Client) LogSyncContext(ctx context.Context, prio int, event string, message string) {
+	clt.logWait(ctx, prio, event, message, waitSync)
 }
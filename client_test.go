@@ -2,20 +2,21 @@ package prowlgo_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
-	"net"
 	"net/http"
-	"net/http/httptest"
-	"net/url"
 	"os"
-	"path"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	prowl "github.com/tweithoener/prowlgo"
+	"github.com/tweithoener/prowlgo/prowltest"
 )
 
 func TestNewClient(t *testing.T) {
@@ -57,6 +58,20 @@ func TestNewClient(t *testing.T) {
 	if err == nil {
 		t.Error("invalid api keys should produce an error")
 	}
+
+	_, err = prowl.NewClient(prowl.Config{
+		LogTimeout: -1 * time.Second,
+	})
+	if err == nil {
+		t.Error("negative log timeout should produce an error")
+	}
+
+	client, err := prowl.NewClient(prowl.Config{})
+	if err != nil {
+		t.Error(err)
+	} else if client.Config().LogTimeout != 30*time.Second {
+		t.Error("log timeout should default to 30 seconds")
+	}
 }
 
 func ExampleNewClient() {
@@ -97,6 +112,7 @@ func ExampleNewClient() {
 func ExampleClient_Add_singleKey() {
 	// Create a new client for sending out message.
 	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
 		APIKeys:     aValidAPIKey,
 		Application: "prowlgo Example",
 	})
@@ -125,6 +141,7 @@ func ExampleClient_Add_multiKey() {
 	//It's not a lot different from sending to a single device -- it's
 	//just multiple api keys in the array this time!
 	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
 		APIKeys:     multipleValidAPIKeys,
 		Application: "prowlgo Example",
 	})
@@ -157,6 +174,7 @@ func ExampleClient_Add_multiKey() {
 func ExampleClient_AddWithURL() {
 	// Create a new client for sending out message.
 	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
 		APIKeys:     aValidAPIKey,
 		Application: "prowlgo Example",
 	})
@@ -182,10 +200,11 @@ func ExampleClient_AddWithURL() {
 }
 
 func TestAdd(t *testing.T) {
-	mock.reset()
-	defer mock.reset()
+	mock.Reset()
+	defer mock.Reset()
 
 	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
 		Application: "prowlgo Example",
 	})
 	if err != nil {
@@ -197,13 +216,14 @@ func TestAdd(t *testing.T) {
 	}
 
 	client, err = prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
 		APIKeys:     aValidAPIKey,
 		Application: "prowlgo Example",
 	})
 	if err != nil {
 		t.Error(err)
 	}
-	mock.acceptAPIKeys = false
+	mock.SetAcceptAPIKeys(false)
 
 	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err == nil {
 		t.Error("illegal api key should produce an error")
@@ -212,9 +232,10 @@ func TestAdd(t *testing.T) {
 		t.Error("client should be unauthorized now")
 	}
 
-	mock.reset()
+	mock.Reset()
 
 	client, err = prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
 		APIKeys:     aValidAPIKey,
 		Application: "prowlgo Example",
 	})
@@ -242,7 +263,7 @@ func TestAdd(t *testing.T) {
 	if _, err := client.AddWithURL(prowl.PrioNormal, "Event", "TEXT", "http://URL/", false); err != nil {
 		t.Error(err)
 	}
-	if mock.lastDescription != "TEXT" {
+	if _, descr := lastAdd(); descr != "TEXT" {
 		t.Error("description was altered")
 	}
 
@@ -250,7 +271,7 @@ func TestAdd(t *testing.T) {
 	if _, err := client.AddWithURL(prowl.PrioNormal, "Event", "TEXT", "http://URL/", true); err != nil {
 		t.Error(err)
 	}
-	if mock.lastDescription != "TEXT http://URL/" {
+	if _, descr := lastAdd(); descr != "TEXT http://URL/" {
 		t.Error("description was not composed correctly")
 	}
 
@@ -258,7 +279,7 @@ func TestAdd(t *testing.T) {
 	if _, err := client.AddWithURL(prowl.PrioNormal, "Event", stringOfLen(9970), stringOfLen(100), true); err != nil {
 		t.Error(err)
 	}
-	if len(mock.lastDescription) > 10000 {
+	if _, descr := lastAdd(); len(descr) > 10000 {
 		t.Error("appending url to description resulted in illegal description")
 	}
 
@@ -268,7 +289,7 @@ func TestAdd(t *testing.T) {
 	}
 
 	//Now the call limit is becoming exceeded ....
-	mock.callLimit = true
+	mock.SetQuotaExhausted(true)
 
 	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err == nil {
 		t.Error("api call limit reached should produce an error")
@@ -279,6 +300,101 @@ func TestAdd(t *testing.T) {
 
 }
 
+func TestHTTPConfig(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	if _, err := prowl.NewClient(prowl.Config{
+		HTTPClient: &http.Client{},
+		Transport:  mock.Transport(),
+	}); err == nil {
+		t.Error("http client and transport should produce an error")
+	}
+	for _, base := range []string{"api.prowlapp.com/publicapi/", "ftp://api.prowlapp.com/", "http://", ":/"} {
+		if _, err := prowl.NewClient(prowl.Config{BaseURL: base}); err == nil {
+			t.Errorf("invalid base url %s should produce an error", base)
+		}
+	}
+
+	//talk to the mock server directly by setting the base url (without trailing slash)
+	client, err := prowl.NewClient(prowl.Config{
+		APIKeys: aValidAPIKey,
+		BaseURL: strings.TrimSuffix(mock.BaseURL(), "/"),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Error(err)
+	}
+	if client.Config().BaseURL != mock.BaseURL() {
+		t.Error("base url was not normalized")
+	}
+
+	//or use an http client which will be used for this client only
+	client, err = prowl.NewClient(prowl.Config{
+		APIKeys:    aValidAPIKey,
+		HTTPClient: &http.Client{Transport: mock.Transport()},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Error(err)
+	}
+
+	//the default http client must be left alone
+	if http.DefaultClient.Transport != nil {
+		t.Error("default http client was modified")
+	}
+}
+
+func TestContext(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		ProviderKey: aValidProviderKey,
+		Token:       "0987609876098760987609876098760987609876",
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	//with a responsive server all context aware calls should work
+	if _, err := client.AddContext(context.Background(), prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Error(err)
+	}
+	if _, err := client.VerifyContext(context.Background(), singleValidAPIKey); err != nil {
+		t.Error(err)
+	}
+
+	//now the server hangs. The requests must return as soon as the deadline is reached.
+	mock.SetDelay(2 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	before := time.Now()
+	if _, err := client.AddWithURLContext(ctx, prowl.PrioNormal, "Event", "Description", "http://URL/", false); err == nil {
+		t.Error("add with expired context should produce an error")
+	}
+	if _, err := client.VerifyContext(ctx, singleValidAPIKey); err == nil {
+		t.Error("verify with expired context should produce an error")
+	}
+	if _, err := client.RetrieveTokenContext(ctx); err == nil {
+		t.Error("retrieve token with expired context should produce an error")
+	}
+	if _, err := client.RetrieveAPIKeyContext(ctx); err == nil {
+		t.Error("retrieve api key with expired context should produce an error")
+	}
+	if time.Since(before) > 1*time.Second {
+		t.Error("requests did not honour the context deadline")
+	}
+}
+
 func ExampleClient_Config() {
 	// Create a new client e.g. for retrieving an api key
 	client, err := prowl.NewClient(prowl.Config{
@@ -324,6 +440,7 @@ func ExampleClient_Log() {
 	toProwlLabel := "--> Prowl"
 	// Create a new client for sending out messages
 	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:      mock.BaseURL(),
 		APIKeys:      aValidAPIKey,
 		Application:  "prowlgo Example",
 		ToProwlLabel: &toProwlLabel,
@@ -353,43 +470,47 @@ func TestLogSync(t *testing.T) {
 	//this is going to be a little more complex:
 	//LogSync()/Log() does not report errors but writes them to the configured log
 	//Thus we need a custom logger to check what is in the logs ...
-	buf := make([]byte, 1000)
-	logbuf := bytes.NewBuffer(buf)
+	//Log writes from another goroutine
+	logbuf := &syncBuffer{}
 	client, err := prowl.NewClient(prowl.Config{
-		APIKeys: aValidAPIKey,
-		Logger:  log.New(logbuf, "", 0),
+		BaseURL:    mock.BaseURL(),
+		APIKeys:    aValidAPIKey,
+		Logger:     log.New(logbuf, "", 0),
+		LogTimeout: 200 * time.Millisecond,
 	})
 	if err != nil {
 		t.Error(err)
 	}
 
-	defer mock.reset()
+	defer mock.Reset()
 
-	if !testing.Short() {
-		mock.reset()
-		//make sure the prowl server mock responds very slow
-		mock.wait = 35 * time.Second
+	mock.Reset()
+	//make sure the prowl server mock responds very slow
+	mock.SetDelay(2 * time.Second)
 
-		before := time.Now()
-		client.Log(prowl.PrioNormal, "TestEvent", "TestDescription")
+	before := time.Now()
+	client.Log(prowl.PrioNormal, "TestEvent", "TestDescription")
 
-		//check if call to Log was async (issue #9)
-		if before.Add(1 * time.Second).Before(time.Now()) {
-			t.Error("function Log() is not async")
-		}
+	//check if call to Log was async (issue #9)
+	if before.Add(100 * time.Millisecond).Before(time.Now()) {
+		t.Error("function Log() is not async")
+	}
 
-		//Log() will timeout after 30 seconds. we should see the error after that.
-		<-time.After(31 * time.Second)
-		logstr := logbuf.String()
-		if !strings.Contains(logstr, "timeout") {
-			t.Error("timeout error expected but not found")
-		}
-	} else {
-		t.Log("skipping timeout test in short mode")
+	//Log() will timeout after LogTimeout. we should see the error after that.
+	waitFor(t, func() bool { return strings.Contains(logbuf.String(), "timeout") })
+
+	mock.Reset()
+	mock.SetDelay(2 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	client.LogSyncContext(ctx, prowl.PrioNormal, "TestEvent", "TestDescription")
+	if strings.Count(logbuf.String(), "timeout") < 2 {
+		t.Error("timeout error expected but not found")
 	}
 
-	mock.reset()
-	mock.acceptAPIKeys = false
+	mock.Reset()
+	mock.SetAcceptAPIKeys(false)
 
 	client.LogSync(prowl.PrioNormal, "0123456789012", "01234567890123456789012")
 	logstr := logbuf.String()
@@ -405,7 +526,7 @@ func TestLogSync(t *testing.T) {
 func ExampleClient_Verify_simple() {
 	// Create a new client
 	//If we just use it to verify an API key we do not need to configure anything.
-	client, err := prowl.NewClient(prowl.Config{})
+	client, err := prowl.NewClient(prowl.Config{BaseURL: mock.BaseURL()})
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -420,11 +541,12 @@ func ExampleClient_Verify_simple() {
 }
 
 func TestVerify(t *testing.T) {
-	defer mock.reset()
+	defer mock.Reset()
 
-	mock.reset()
+	mock.Reset()
 	//Create a client with provider key
 	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
 		ProviderKey: aValidProviderKey,
 	})
 	if err != nil {
@@ -438,23 +560,24 @@ func TestVerify(t *testing.T) {
 
 	//And more things that should not work...
 	//A provider key is not a api key
-	client, err = prowl.NewClient(prowl.Config{})
+	client, err = prowl.NewClient(prowl.Config{BaseURL: mock.BaseURL()})
 	if err != nil {
 		t.Error(err)
 	}
 
-	mock.acceptAPIKeys = false
+	mock.SetAcceptAPIKeys(false)
 
 	//Verify this client -- should produce an error
 	if _, err = client.Verify(aValidProviderKey); err == nil {
 		t.Error("vrifying an invalid api key should have produced an error")
 	}
 
-	mock.reset()
-	mock.acceptProviderKey = false
+	mock.Reset()
+	mock.SetAcceptProviderKey(false)
 
 	//A provider key that does not validate is not ap orblem.
 	client, err = prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
 		ProviderKey: singleValidAPIKey,
 	})
 	if err != nil {
@@ -468,7 +591,7 @@ func TestVerify(t *testing.T) {
 	}
 
 	//And finally no key at all -- can't work either
-	client, err = prowl.NewClient(prowl.Config{})
+	client, err = prowl.NewClient(prowl.Config{BaseURL: mock.BaseURL()})
 	if err != nil {
 		t.Error(err)
 	}
@@ -479,14 +602,14 @@ func TestVerify(t *testing.T) {
 		t.Error("verifying an empty kes should have produced an error")
 	}
 
-	mock.reset()
-	mock.incomplete = true
+	mock.Reset()
+	mock.SetMalformed(true)
 
 	if _, err := client.Verify(singleValidAPIKey); err == nil {
 		t.Error("incomplete response should have produced an error")
 	}
 
-	mock.stop()
+	mock.SetOffline(true)
 
 	if _, err := client.Verify(singleValidAPIKey); err == nil {
 		t.Error("server not responding should have produced an error")
@@ -496,6 +619,7 @@ func TestVerify(t *testing.T) {
 func ExampleClient_RetrieveToken() {
 	//A client that is good to retrieve a token
 	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
 		ProviderKey: aValidProviderKey,
 	})
 	if err != nil {
@@ -513,10 +637,10 @@ func ExampleClient_RetrieveToken() {
 	//Now present the approveURL to the user and wait for his approval.
 	fmt.Println("Please approve api key request at:", approveURL)
 
-	//... user approves ...
-
-	//User has approved the URL, let's continue
-	_, err = client.RetrieveAPIKey()
+	//Wait until the user has approved the request. Give up after 10 minutes.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	_, err = client.WaitForAPIKey(ctx, 5*time.Second)
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -526,10 +650,10 @@ func ExampleClient_RetrieveToken() {
 }
 
 func TestRetrieveTokenAndAPIKey(t *testing.T) {
-	mock.reset()
-	defer mock.reset()
+	mock.Reset()
+	defer mock.Reset()
 
-	client, err := prowl.NewClient(prowl.Config{})
+	client, err := prowl.NewClient(prowl.Config{BaseURL: mock.BaseURL()})
 	if err != nil {
 		t.Error(err)
 	}
@@ -545,7 +669,8 @@ func TestRetrieveTokenAndAPIKey(t *testing.T) {
 	}
 
 	client, err = prowl.NewClient(prowl.Config{
-		Token: "0987609876098760987609876098760987609876",
+		BaseURL: mock.BaseURL(),
+		Token:   "0987609876098760987609876098760987609876",
 	})
 	if err != nil {
 		t.Error(err)
@@ -557,6 +682,7 @@ func TestRetrieveTokenAndAPIKey(t *testing.T) {
 	}
 
 	client, err = prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
 		ProviderKey: "0123401234012340123401234012340123401234",
 		Token:       "0987609876098760987609876098760987609876",
 	})
@@ -564,7 +690,7 @@ func TestRetrieveTokenAndAPIKey(t *testing.T) {
 		t.Error(err)
 	}
 
-	mock.acceptProviderKey = false
+	mock.SetAcceptProviderKey(false)
 	//retrieve token with invalid provider key will fail
 	if _, err := client.RetrieveToken(); err == nil {
 		t.Error("retrieve token with invalid provider key should produce an error")
@@ -575,15 +701,15 @@ func TestRetrieveTokenAndAPIKey(t *testing.T) {
 		t.Error("retrieve api key wit invalid provider key should produce an error")
 	}
 
-	mock.acceptProviderKey = true
-	mock.acceptToken = false
+	mock.SetAcceptProviderKey(true)
+	mock.SetTokenApproved(false)
 
 	//retrieve api key with invalid token will fail
 	if _, err := client.RetrieveAPIKey(); err == nil {
 		t.Error("retrieve api key with invalid token should produce an error")
 	}
 
-	mock.stop()
+	mock.SetOffline(true)
 
 	//both request against stopped server will fail
 	if _, err := client.RetrieveAPIKey(); err == nil {
@@ -593,7 +719,7 @@ func TestRetrieveTokenAndAPIKey(t *testing.T) {
 		t.Error("retrieve api key against server not running should fail")
 	}
 
-	mock.reset()
+	mock.Reset()
 
 	//let's go through the process again with no error:
 	if _, err := client.RetrieveToken(); err != nil {
@@ -617,7 +743,7 @@ func TestRetrieveTokenAndAPIKey(t *testing.T) {
 		t.Error("new api key was not found in client config")
 	}
 
-	mock.incomplete = true
+	mock.SetMalformed(true)
 
 	//both should produce an error
 	if _, err := client.RetrieveToken(); err == nil {
@@ -629,14 +755,63 @@ func TestRetrieveTokenAndAPIKey(t *testing.T) {
 
 }
 
+func TestWaitForAPIKey(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		ProviderKey: aValidProviderKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.RetrieveToken(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.WaitForAPIKey(context.Background(), 0); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Error("zero interval should produce an error")
+	}
+
+	//the user approves after a while
+	mock.ApproveTokenAfter(3)
+	apiKey, err := client.WaitForAPIKey(context.Background(), 10*time.Millisecond)
+	if err != nil || apiKey != prowltest.APIKey {
+		t.Errorf("unexpected api key %s: %v", apiKey, err)
+	}
+	if n := len(mock.Requests()); n != 5 {
+		t.Errorf("expected 5 requests, got %d", n)
+	}
+
+	//the user never approves
+	mock.SetTokenApproved(false)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = client.WaitForAPIKey(ctx, 10*time.Millisecond)
+	var timeout *prowl.ApprovalTimeoutError
+	if !errors.As(err, &timeout) || timeout.Attempts == 0 {
+		t.Errorf("approval timeout expected: %v", err)
+	}
+	if !errors.Is(err, prowl.ErrTokenNotApproved) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("timeout should match token not approved and deadline exceeded: %v", err)
+	}
+
+	//other errors end the wait right away
+	mock.SetAcceptProviderKey(false)
+	if _, err := client.WaitForAPIKey(context.Background(), time.Hour); !errors.Is(err, prowl.ErrUnauthorized) {
+		t.Errorf("unauthorized expected: %v", err)
+	}
+}
+
 func TestReset(t *testing.T) {
-	mock.reset()
-	defer mock.reset()
+	mock.Reset()
+	defer mock.Reset()
 
 	resetTS := time.Now().Add(2 * time.Minute).Unix()
-	mock.resetTS = resetTS
+	mock.SetResetDate(time.Unix(resetTS, 0))
 
-	client, err := prowl.NewClient(prowl.Config{})
+	client, err := prowl.NewClient(prowl.Config{BaseURL: mock.BaseURL()})
 	if err != nil {
 		t.Error(err)
 	}
@@ -699,7 +874,7 @@ func ExampleClient_AddAPIKey() {
 
 func TestAddRemoveAPIKeys(t *testing.T) {
 
-	client, err := prowl.NewClient(prowl.Config{})
+	client, err := prowl.NewClient(prowl.Config{BaseURL: mock.BaseURL()})
 	if err != nil {
 		t.Error(err)
 	}
@@ -746,179 +921,164 @@ func TestAddRemoveAPIKeys(t *testing.T) {
 
 	p1 := key1 + "," + key2
 	p2 := key2 + "," + key1
-	if mock.lastAPIKey != p1 && mock.lastAPIKey != p2 {
-		log.Println(mock.lastAPIKey)
+	if apiKey, _ := lastAdd(); apiKey != p1 && apiKey != p2 {
+		log.Println(apiKey)
 		t.Error("apikey request parameter is not correct")
 	}
 }
 
-// ----------------------------------------------------------------------------------------------
-// Mocking a https server during testing
+func TestConcurrency(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
 
-func prowlMockHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("content-type", "text/xml")
+	//run with -race to make this test meaningful
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     multipleValidAPIKeys,
+		ProviderKey: aValidProviderKey,
+		Application: "prowlgo Test",
+		Logger:      log.New(ioutil.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extraKey := "1111111111111111111111111111111111111111"
+	errs := make(chan error, 100)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := client.Add(prowl.PrioNormal, "Event", fmt.Sprintf("Description %d", i)); err != nil {
+				errs <- err
+			}
+			if _, err := client.AddWithURL(prowl.PrioHigh, "Event", "Description", "http://URL/", true); err != nil {
+				errs <- err
+			}
+			if _, err := client.Verify(singleValidAPIKey); err != nil {
+				errs <- err
+			}
+			client.LogSync(prowl.PrioModerate, "Event", "Description")
+		}(i)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.AddAPIKey(extraKey); err != nil {
+				errs <- err
+			}
+			if len(client.Config().APIKeys) < len(multipleValidAPIKeys) {
+				errs <- fmt.Errorf("api keys got lost")
+			}
+			if err := client.RemoveAPIKey(extraKey); err != nil {
+				errs <- err
+			}
+			if client.Reset().Before(time.Now()) {
+				errs <- fmt.Errorf("reset time is in the past")
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.RetrieveToken(); err != nil {
+				errs <- err
+			}
+			if _, err := client.RetrieveAPIKey(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
 
-	<-time.After(mock.wait)
+func TestConcurrencyLog(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
 
-	if mock.internalError {
-		w.WriteHeader(500)
-		fmt.Sprintln(w, internalError)
-		return
+	//Log() sends from its own goroutines. Check that this does not race with
+	//other operations on the client.
+	logbuf := &syncBuffer{}
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Logger:  log.New(logbuf, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if mock.incomplete {
-		w.WriteHeader(200)
-		fmt.Sprintln(w, incompleteXML)
-		return
+	for i := 0; i < 10; i++ {
+		client.Log(prowl.PrioNormal, "Event", "Description")
 	}
-
-	remaining := 992
-	if mock.callLimit {
-		remaining = 0
+	mock.SetAcceptAPIKeys(false)
+	for i := 0; i < 10; i++ {
+		client.Add(prowl.PrioNormal, "Event", "Description")
+		client.Config()
 	}
 
-	switch r.URL.Path {
-	case "/publicapi/add":
-		if r.FormValue("apikey") == "" {
-			w.WriteHeader(401)
-			fmt.Fprintf(w, apiKeyRequired)
-			return
-		}
-		if remaining == 0 {
-			w.WriteHeader(406)
-			fmt.Fprintf(w, callLimitExceeded)
-			return
-		}
-		if !mock.acceptAPIKeys {
-			w.WriteHeader(401)
-			fmt.Fprintf(w, invalidAPIKey)
-			return
-		}
-
-		w.WriteHeader(200)
-		fmt.Fprintf(w, add200, remaining, mock.resetTS)
-		mock.lastDescription = r.FormValue("description")
-		mock.lastAPIKey = r.FormValue("apikey")
-
-	case "/publicapi/verify":
-		if r.URL.Query().Get("apikey") == "" {
-			w.WriteHeader(401)
-			fmt.Fprintf(w, apiKeyRequired)
-			return
-		}
-
-		if mock.acceptAPIKeys {
-			w.WriteHeader(200)
-			fmt.Fprintf(w, verify200, remaining, mock.resetTS)
-			return
-		}
-
-		w.WriteHeader(401)
-		fmt.Fprintf(w, invalidAPIKey)
-
-	case "/publicapi/retrieve/token":
-		if r.URL.Query().Get("providerkey") == "" || !mock.acceptProviderKey {
-			w.WriteHeader(401)
-			fmt.Fprintf(w, providerKeyRequired)
-			return
-		}
-
-		w.WriteHeader(200)
-		fmt.Fprintf(w, retrieveToken200, remaining, mock.resetTS)
-
-	case "/publicapi/retrieve/apikey":
-		if r.URL.Query().Get("token") == "" {
-			w.WriteHeader(401)
-			fmt.Fprintf(w, tokenIsRequired)
-			return
-		}
-		if r.URL.Query().Get("providerkey") == "" || !mock.acceptProviderKey {
-			w.WriteHeader(401)
-			fmt.Fprintf(w, providerKeyRequired)
-			return
-		}
-
-		if mock.acceptToken {
-			w.WriteHeader(200)
-			fmt.Fprintf(w, retrieveAPIKey200, remaining, mock.resetTS)
-			return
-		}
-
-		w.WriteHeader(409)
-		fmt.Fprintf(w, tokenNotApproved)
+	//give the log goroutines the chance to finish
+	<-time.After(500 * time.Millisecond)
+	if strings.Count(logbuf.String(), prowl.DefaultToProwlLabel) != 10 {
+		t.Error("not all messages have been logged")
 	}
 }
 
-type mockServer struct {
-	acceptToken       bool
-	acceptProviderKey bool
-	acceptAPIKeys     bool
-	callLimit         bool
-	incomplete        bool
-	internalError     bool
-	wait              time.Duration
-	resetTS           int64
-	lastDescription   string
-	lastAPIKey        string
-	server            *httptest.Server
+// syncBuffer is a bytes.Buffer which can be written to and read from concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
 }
 
-func (ms *mockServer) start() {
-	if ms.server != nil {
-		return
-	}
-	ms.server = httptest.NewServer(http.HandlerFunc(prowlMockHandler))
-
-	mockURL, err := url.Parse(mock.server.URL)
-	if err != nil {
-		log.Fatal("error parsing mock server url:", err)
-	}
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.Write(p)
+}
 
-	//create a http client using that transport
-	testClient := &http.Client{
-		Transport: RewriteTransport{
-			Transport: &http.Transport{
-				Dial: func(network, addr string) (net.Conn, error) {
-					return net.DialTimeout(network, addr, 3*time.Second)
-				},
-			},
-			URL: mockURL,
-		},
-	}
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.String()
+}
 
-	//inject this client
-	http.DefaultClient = testClient
+// ----------------------------------------------------------------------------------------------
+// Mocking a https server during testing
 
-}
+var mock *prowltest.Server
 
-func (ms *mockServer) stop() {
-	if ms.server == nil {
-		return
+// lastAdd returns the api key and description of the last successful add request.
+func lastAdd() (apiKey string, description string) {
+	req, ok := mock.LastAdd()
+	if !ok {
+		return "", ""
 	}
-	ms.server.Close()
-	ms.server = nil
+	return req.Params.Get("apikey"), req.Params.Get("description")
 }
 
-func (ms *mockServer) reset() {
-	ms.acceptAPIKeys = true
-	ms.acceptProviderKey = true
-	ms.acceptToken = true
-	ms.incomplete = false
-	ms.internalError = false
-	ms.callLimit = false
-	ms.wait = 0
-	ms.start()
-	ms.resetTS = time.Now().Add(37 * time.Minute).Unix()
+// addedDescriptions returns the descriptions of all successful add requests since the last reset.
+func addedDescriptions() []string {
+	var descr []string
+	for _, req := range mock.Adds() {
+		descr = append(descr, req.Params.Get("description"))
+	}
+	return descr
 }
 
-var mock = &mockServer{}
-
 func TestMain(m *testing.M) {
 	//setup a mock http server
-	mock.reset()
+	mock = prowltest.NewServer()
 
 	//run the tests
-	os.Exit(m.Run())
+	code := m.Run()
+	mock.Close()
+	os.Exit(code)
 }
 
 func stringOfLen(len int) string {
@@ -935,107 +1095,3 @@ var (
 	multipleValidAPIKeys = []string{"e192384beae856efa6dda87d6a00837cf968bd8c", "e19238423ae856efa6ddadf34a00837cf968bd8c", "e17ef34beae856efa6dda87d6a0082130168bd8c"}
 	aValidProviderKey    = "0267157cc27a27f99ad23d1f785f0e7897df0d6b"
 )
-
-const invalidAPIKey = `
-<?xml version="1.0" encoding="UTF-8"?>
-<prowl>
-<error code="401">Invalid API key</error>
-</prowl>
-`
-
-const apiKeyRequired = `
-<?xml version="1.0" encoding="UTF-8"?>
-<prowl>
-<error code="401">API key is required</error>
-</prowl>
-`
-
-const verify200 = `
-<?xml version="1.0" encoding="UTF-8"?>
-<prowl>
-<success code="200" remaining="%d" resetdate="%d" />
-</prowl>
-`
-
-const add200 = verify200
-
-const providerKeyRequired = `
-<?xml version="1.0" encoding="UTF-8"?>
-<prowl>
-<error code="401">Provider key is required.</error>
-</prowl>
-`
-
-const callLimitExceeded = `
-<?xml version="1.0" encoding="UTF-8"?>
-<prowl>
-<error code="406">Not acceptable, your IP address has exceeded the API limit</error>
-</prowl>
-`
-
-const invalidProviderKey = `
-<?xml version="1.0" encoding="UTF-8"?>
-<prowl>
-<error code="401">Invalid provider key.</error>
-</prowl>
-`
-
-const retrieveToken200 = `
-<?xml version="1.0" encoding="UTF-8"?>
-<prowl>
-<success code="200" remaining="%d" resetdate="%d" />
-<retrieve token="c3fb7c3fb7c3fb7c3fb7c3fb7c3fb7c3fb7c3fb7" url="https://www.prowlapp.com/retrieve.php?token=c3fb7c3fb7c3fb7c3fb7c3fb7c3fb7c3fb7c3fb7" />
-</prowl>
-`
-
-const tokenNotApproved = `
-<?xml version="1.0" encoding="UTF-8"?>
-<prowl>
-<error code="409">The user has not approved your access.</error>
-</prowl>
-`
-
-const tokenIsRequired = `
-<?xml version="1.0" encoding="UTF-8"?>
-<prowl>
-<error code="400">Token is required.</error>
-</prowl>
-`
-
-const retrieveAPIKey200 = `
-<?xml version="1.0" encoding="UTF-8"?>
-<prowl>
-<success code="200" remaining="%d" resetdate="%d" />
-<retrieve apikey="3fa013fa013fa013fa013fa013fa013fa013fa01" />
-</prowl>
-`
-
-const internalError = `
-<prowl>
-<error code="500">Somethign went wrong.</error>
-</prowl>
-`
-
-const incompleteXML = `
-<prowl>
-<error code="500">Somethign we
-`
-
-type RewriteTransport struct {
-	Transport http.RoundTripper
-	URL       *url.URL
-}
-
-func (rwt RewriteTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
-	req.URL.Scheme = rwt.URL.Scheme
-	req.URL.Host = rwt.URL.Host
-	req.URL.Path = path.Join(rwt.URL.Path, req.URL.Path)
-
-	rt := rwt.Transport
-	if rt == nil {
-		rt = http.DefaultTransport
-	}
-
-	resp, err = rt.RoundTrip(req)
-	return
-}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+// fileConfig is the content of the config file.
+type fileConfig struct {
+	APIKeys     []string `json:"api_keys,omitempty"`
+	ProviderKey string   `json:"provider_key,omitempty"`
+	Application string   `json:"application,omitempty"`
+	//Recipients maps names to api keys. See "prowl send -to".
+	Recipients map[string]string `json:"recipients,omitempty"`
+	//Groups maps group names to recipient names.
+	Groups map[string][]string `json:"groups,omitempty"`
+	//Topics maps topics to the recipients and groups that subscribed to them.
+	Topics map[string][]string `json:"topics,omitempty"`
+	//Rotations maps names to on-call rotations. See prowl.Rotation.
+	Rotations map[string]prowl.Rotation `json:"rotations,omitempty"`
+	//SilenceFile is the file the silences are kept in. Relative paths are relative to the
+	//directory of the config file. Defaults to silences.json next to the config file.
+	SilenceFile string `json:"silence_file,omitempty"`
+	//BaseURL is the url of the prowl api. Only needed to talk to something else than
+	//the real prowl server.
+	BaseURL string `json:"base_url,omitempty"`
+}
+
+// loadConfig reads the config file at path. A missing file is fine unless it was
+// named explicitly.
+func loadConfig(path string, explicit bool) (fc fileConfig, err error) {
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return fc, nil
+	}
+	if err != nil {
+		return fc, fmt.Errorf("can't read config file: %w", err)
+	}
+	if err = json.Unmarshal(buf, &fc); err != nil {
+		return fc, fmt.Errorf("can't parse config file %s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// saveConfig writes the config file at path. The directory is created if necessary. The
+// file is replaced atomically so that it is never left half written.
+func saveConfig(path string, fc fileConfig) error {
+	buf, err := json.MarshalIndent(fc, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("can't create config dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, append(buf, '\n'), 0600); err != nil {
+		return fmt.Errorf("can't write config file: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("can't write config file: %w", err)
+	}
+	return nil
+}
+
+// clientFlags are the flags every command talking to prowl understands.
+type clientFlags struct {
+	apiKeys     string
+	providerKey string
+	application string
+	configPath  string
+}
+
+func (cf *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&cf.apiKeys, "keys", "", "comma separated `api keys` (default $PROWL_API_KEYS or config file)")
+	fs.StringVar(&cf.providerKey, "provider-key", "", "provider `key` (default $PROWL_PROVIDER_KEY or config file)")
+	fs.StringVar(&cf.application, "application", "", "application `name` (default $PROWL_APPLICATION or config file)")
+	fs.StringVar(&cf.configPath, "config", "", "config `file` (default $PROWL_CONFIG or prowl/config.json in the user config dir)")
+}
+
+// path returns the path of the config file and whether it was named explicitly.
+func (cf *clientFlags) path(e *env) (path string, explicit bool, err error) {
+	if len(cf.configPath) > 0 {
+		return cf.configPath, true, nil
+	}
+	if path = e.getenv("PROWL_CONFIG"); len(path) > 0 {
+		return path, true, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false, fmt.Errorf("can't locate config file: %w", err)
+	}
+	return filepath.Join(dir, "prowl", "config.json"), false, nil
+}
+
+// config merges flags, environment and config file into a client config. Flags take
+// precedence over the environment which takes precedence over the config file.
+func (cf *clientFlags) config(e *env) (prowl.Config, error) {
+	path, explicit, err := cf.path(e)
+	if err != nil {
+		return prowl.Config{}, err
+	}
+	fc, err := loadConfig(path, explicit)
+	if err != nil {
+		return prowl.Config{}, err
+	}
+
+	config := prowl.Config{
+		APIKeys:     fc.APIKeys,
+		ProviderKey: first(cf.providerKey, e.getenv("PROWL_PROVIDER_KEY"), fc.ProviderKey),
+		Application: first(cf.application, e.getenv("PROWL_APPLICATION"), fc.Application),
+		BaseURL:     fc.BaseURL,
+		Recipients:  fc.Recipients,
+		Groups:      fc.Groups,
+		Topics:      fc.Topics,
+		Rotations:   fc.Rotations,
+		SilenceFile: first(fc.SilenceFile, "silences.json"),
+	}
+	if !filepath.IsAbs(config.SilenceFile) {
+		config.SilenceFile = filepath.Join(filepath.Dir(path), config.SilenceFile)
+	}
+	if keys := first(cf.apiKeys, e.getenv("PROWL_API_KEYS")); len(keys) > 0 {
+		config.APIKeys = splitKeys(keys)
+	}
+	return config, nil
+}
+
+// first returns the first non empty value.
+func first(values ...string) string {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
+
+func splitKeys(keys string) (ret []string) {
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); len(key) > 0 {
+			ret = append(ret, key)
+		}
+	}
+	return
+}
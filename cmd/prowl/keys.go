@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+// keys implements the keys command.
+func keys(args []string, e *env) int {
+	if len(args) == 0 {
+		keysUsage(e.stderr)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "retrieve":
+		return keysRetrieve(args[1:], e)
+	case "help", "-h", "-help", "--help":
+		keysUsage(e.stdout)
+		return exitOK
+	}
+
+	fmt.Fprintf(e.stderr, "prowl: unknown keys command %q\n", args[0])
+	keysUsage(e.stderr)
+	return exitUsage
+}
+
+func keysUsage(w io.Writer) {
+	fmt.Fprint(w, `Usage: prowl keys <command> [flags]
+
+Commands:
+  retrieve    retrieve a new api key and save it to the config file
+`)
+}
+
+// keysRetrieve implements the keys retrieve command. It retrieves a token, asks the user
+// to approve it, showing the approve url as text and as a QR code, and waits until the api
+// key can be retrieved.
+func keysRetrieve(args []string, e *env) int {
+	fs := flag.NewFlagSet("prowl keys retrieve", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+
+	var cf clientFlags
+	cf.register(fs)
+	timeout := fs.Duration("timeout", 5*time.Minute, "give up if the token is not approved within this `duration`")
+	interval := fs.Duration("interval", 5*time.Second, "poll the prowl server in this `interval`")
+	noSave := fs.Bool("no-save", false, "print the api key but do not save it to the config file")
+	noQR := fs.Bool("no-qr", false, "do not show the approve url as a QR code")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		return fail(e, usageErrorf("unexpected argument %q", fs.Arg(0)))
+	}
+	if *interval <= 0 {
+		return fail(e, usageErrorf("interval must be positive"))
+	}
+
+	config, err := cf.config(e)
+	if err != nil {
+		return fail(e, err)
+	}
+	if len(config.ProviderKey) == 0 {
+		return fail(e, usageErrorf("a provider key is required to retrieve an api key"))
+	}
+	client, err := prowl.NewClient(prowl.Config{
+		ProviderKey: config.ProviderKey,
+		BaseURL:     config.BaseURL,
+	})
+	if err != nil {
+		return fail(e, err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	approveURL, err := client.RetrieveTokenContext(ctx)
+	if err != nil {
+		return fail(e, err)
+	}
+	fmt.Fprintf(e.stderr, "Please approve the api key request at:\n\n  %s\n\n", approveURL)
+	if !*noQR {
+		//a QR code is a convenience, the url above is enough to go on without it
+		if qr, err := encodeQR(approveURL); err == nil {
+			fmt.Fprint(e.stderr, "or scan this QR code with your phone:\n\n")
+			qr.render(e.stderr)
+			fmt.Fprintln(e.stderr)
+		}
+	}
+	fmt.Fprintln(e.stderr, "Waiting for approval ...")
+
+	apiKey, err := client.WaitForAPIKey(ctx, *interval)
+	if err != nil {
+		return fail(e, err)
+	}
+	fmt.Fprintln(e.stdout, apiKey)
+
+	if *noSave {
+		return exitOK
+	}
+	path, _, err := cf.path(e)
+	if err != nil {
+		return fail(e, err)
+	}
+	if err = addKeyToConfig(path, apiKey); err != nil {
+		return fail(e, err)
+	}
+	fmt.Fprintf(e.stderr, "api key saved to %s\n", path)
+	return exitOK
+}
+
+// addKeyToConfig adds the api key to the config file at path. The file is created if
+// it does not exist.
+func addKeyToConfig(path string, apiKey string) error {
+	fc, err := loadConfig(path, false)
+	if err != nil {
+		return err
+	}
+	for _, key := range fc.APIKeys {
+		if key == apiKey {
+			return nil
+		}
+	}
+	fc.APIKeys = append(fc.APIKeys, apiKey)
+	return saveConfig(path, fc)
+}
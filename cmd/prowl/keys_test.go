@@ -0,0 +1,90 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tweithoener/prowlgo/prowltest"
+)
+
+const providerKey = "0267157cc27a27f99ad23d1f785f0e7897df0d6b"
+
+func TestKeysRetrieve(t *testing.T) {
+	srv := prowltest.NewServer()
+	defer srv.Close()
+
+	te := newTestEnv(t, srv, `{"api_keys": ["`+apiKey+`"], "provider_key": "`+providerKey+`", "base_url": "BASEURL"}`)
+	path := te.vars["PROWL_CONFIG"]
+
+	srv.ApproveTokenAfter(2)
+	if code := te.run("keys", "retrieve", "-interval", "10ms"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	if strings.TrimSpace(te.stdout.String()) != prowltest.APIKey {
+		t.Errorf("api key not printed: %s", te.stdout.String())
+	}
+	if !strings.Contains(te.stderr.String(), prowltest.ApproveURL) {
+		t.Errorf("approve url not shown: %s", te.stderr.String())
+	}
+	if !strings.Contains(te.stderr.String(), "QR code") {
+		t.Errorf("QR code not shown: %s", te.stderr.String())
+	}
+
+	//the new key was added to the config file, everything else is left alone
+	fc, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.APIKeys) != 2 || fc.APIKeys[0] != apiKey || fc.APIKeys[1] != prowltest.APIKey || fc.ProviderKey != providerKey {
+		t.Errorf("unexpected config %+v", fc)
+	}
+
+	//retrieving the same key again does not duplicate it
+	if code := te.run("keys", "retrieve", "-interval", "10ms", "-no-qr"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	if strings.Contains(te.stderr.String(), "QR code") {
+		t.Errorf("QR code shown despite -no-qr: %s", te.stderr.String())
+	}
+	if fc, _ := loadConfig(path, true); len(fc.APIKeys) != 2 {
+		t.Errorf("unexpected config %+v", fc)
+	}
+
+	//a config file is created if there is none
+	path = filepath.Join(te.dir, "new", "config.json")
+	if err := addKeyToConfig(path, apiKey); err != nil {
+		t.Fatal(err)
+	}
+	if fc, err := loadConfig(path, true); err != nil || len(fc.APIKeys) != 1 {
+		t.Errorf("unexpected config %+v: %v", fc, err)
+	}
+}
+
+func TestKeysRetrieveExitCodes(t *testing.T) {
+	srv := prowltest.NewServer()
+	defer srv.Close()
+
+	te := newTestEnv(t, srv, `{"provider_key": "`+providerKey+`", "base_url": "BASEURL"}`)
+
+	srv.SetTokenApproved(false)
+	if code := te.run("keys", "retrieve", "-interval", "10ms", "-timeout", "100ms"); code != exitAuth {
+		t.Errorf("expected exit code %d, got %d: %s", exitAuth, code, te.stderr.String())
+	}
+
+	srv.Reset()
+	srv.SetAcceptProviderKey(false)
+	if code := te.run("keys", "retrieve"); code != exitAuth {
+		t.Errorf("expected exit code %d, got %d: %s", exitAuth, code, te.stderr.String())
+	}
+
+	if code := te.run("keys", "retrieve", "-no-save", "-interval", "0"); code != exitUsage {
+		t.Errorf("expected exit code %d, got %d", exitUsage, code)
+	}
+	if code := te.run("keys"); code != exitUsage {
+		t.Errorf("expected exit code %d, got %d", exitUsage, code)
+	}
+	if code := te.run("keys", "forget"); code != exitUsage {
+		t.Errorf("expected exit code %d, got %d", exitUsage, code)
+	}
+}
@@ -0,0 +1,164 @@
+// Command prowl sends push notifications to iOS devices via prowl from the command line.
+//
+// Usage:
+//
+//	prowl send -event "Backup" -description "Backup finished" [flags]
+//	echo "Disk is full" | prowl send -priority high -event "Disk" -stdin
+//	prowl keys retrieve -provider-key 0123456789012345678901234567890123456789
+//	prowl silence add -event "DB.*" -duration 2h -comment "database maintenance"
+//
+// Run "prowl <command> -h" to list the flags of a command.
+//
+// "prowl keys retrieve" runs the flow described at Client.RetrieveToken: it prints the URL
+// at which the user approves the request, waits until the request was approved and adds the
+// new api key to the config file.
+//
+// API keys, the provider key and the application name are taken from the command line flags,
+// from the environment variables PROWL_API_KEYS (comma separated), PROWL_PROVIDER_KEY and
+// PROWL_APPLICATION, or from a JSON config file, in this order. The config file is
+// $XDG_CONFIG_HOME/prowl/config.json (or the equivalent of your OS) unless -config or the
+// environment variable PROWL_CONFIG name a different file:
+//
+//	{
+//		"api_keys": ["0123456789012345678901234567890123456789"],
+//		"provider_key": "0123456789012345678901234567890123456789",
+//		"application": "backup",
+//		"recipients": {"ops-phone": "0123456789012345678901234567890123456789"},
+//		"groups": {"oncall": ["ops-phone"]},
+//		"topics": {"db": ["oncall"], "deploys": ["primary"]},
+//		"rotations": {"primary": {
+//			"members": ["ops-phone"],
+//			"start": "2024-01-01T09:00:00+01:00",
+//			"timezone": "Europe/Berlin"
+//		}}
+//	}
+//
+// Notifications go to all api keys unless "prowl send -to" names some of the recipients,
+// groups or rotations or "prowl send -topic" names a topic.
+//
+// "prowl silence add", "prowl silence list" and "prowl silence expire" manage silences (see
+// prowl.Silence). They are kept in silences.json next to the config file unless the config
+// file names a different "silence_file". Programs using the same file as their
+// Config.SilenceFile pick up the silences without a restart.
+//
+// The exit code tells what went wrong:
+//
+//	0  success
+//	1  any other error
+//	2  illegal flags or arguments
+//	3  api key, provider key or token was rejected
+//	4  api call limit exceeded
+//	5  prowl server could not be reached
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+const (
+	exitOK        = 0
+	exitFailure   = 1
+	exitUsage     = 2
+	exitAuth      = 3
+	exitQuota     = 4
+	exitTransport = 5
+)
+
+// env is the environment a command runs in. Tests replace it to run commands in-process.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], &env{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		getenv: os.Getenv,
+	}))
+}
+
+// run executes the command given by args and returns the exit code.
+func run(args []string, e *env) int {
+	if len(args) == 0 {
+		usage(e.stderr)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "send":
+		return send(args[1:], e)
+	case "keys":
+		return keys(args[1:], e)
+	case "silence":
+		return silence(args[1:], e)
+	case "help", "-h", "-help", "--help":
+		usage(e.stdout)
+		return exitOK
+	}
+
+	fmt.Fprintf(e.stderr, "prowl: unknown command %q\n", args[0])
+	usage(e.stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage: prowl <command> [flags]
+
+Commands:
+  send    send a notification
+  keys    manage api keys
+  silence manage silences
+
+Run "prowl <command> -h" to list the flags of a command.
+`)
+}
+
+// exitCode maps errors returned by the client to exit codes.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, prowl.ErrInvalidArgument):
+		return exitUsage
+	case errors.Is(err, prowl.ErrUnauthorized), errors.Is(err, prowl.ErrTokenNotApproved):
+		return exitAuth
+	case errors.Is(err, prowl.ErrQuotaExceeded):
+		return exitQuota
+	case errors.Is(err, prowl.ErrTransport), errors.Is(err, context.DeadlineExceeded):
+		return exitTransport
+	}
+	return exitFailure
+}
+
+// fail reports err and returns the matching exit code.
+func fail(e *env, err error) int {
+	fmt.Fprintf(e.stderr, "prowl: %s\n", err)
+	return exitCode(err)
+}
+
+// usageError is an error in the command line. It matches prowl.ErrInvalidArgument.
+type usageError struct {
+	msg string
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func (e *usageError) Is(target error) bool {
+	return target == prowl.ErrInvalidArgument
+}
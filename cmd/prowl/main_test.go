@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tweithoener/prowlgo/prowltest"
+)
+
+const (
+	apiKey      = "e192384beae856efa6dda87d6a00837cf968bd8c"
+	otherAPIKey = "e19238423ae856efa6ddadf34a00837cf968bd8c"
+)
+
+// testEnv runs commands against srv. The config file in dir points to srv.
+type testEnv struct {
+	env
+	dir    string
+	vars   map[string]string
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+func newTestEnv(t *testing.T, srv *prowltest.Server, config string) *testEnv {
+	te := &testEnv{
+		dir:  t.TempDir(),
+		vars: make(map[string]string),
+	}
+	if len(config) > 0 {
+		path := filepath.Join(te.dir, "config.json")
+		config = strings.Replace(config, "BASEURL", srv.BaseURL(), 1)
+		if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		te.vars["PROWL_CONFIG"] = path
+	}
+	te.env = env{
+		stdin:  strings.NewReader(""),
+		stdout: &te.stdout,
+		stderr: &te.stderr,
+		getenv: func(key string) string { return te.vars[key] },
+	}
+	return te
+}
+
+func (te *testEnv) run(args ...string) int {
+	te.stdout.Reset()
+	te.stderr.Reset()
+	return run(args, &te.env)
+}
+
+func TestSend(t *testing.T) {
+	srv := prowltest.NewServer()
+	defer srv.Close()
+
+	te := newTestEnv(t, srv, `{"api_keys": ["`+apiKey+`"], "application": "from file", "base_url": "BASEURL",
+		"recipients": {"ops": "`+otherAPIKey+`"}, "topics": {"db": ["ops"]},
+		"rotations": {"primary": {"members": ["ops"], "start": "2024-01-01T09:00:00Z", "days": 1, "timezone": "UTC"}}}`)
+
+	if code := te.run("send", "-event", "Event", "-description", "Description", "-priority", "high", "-url", "http://example.com/"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	last, _ := srv.LastAdd()
+	if last.Params.Get("application") != "from file" || last.Params.Get("priority") != "1" ||
+		last.Params.Get("event") != "Event" || last.Params.Get("description") != "Description" ||
+		last.Params.Get("url") != "http://example.com/" {
+		t.Errorf("unexpected request %v", last.Params)
+	}
+
+	//environment beats config file, flags beat environment
+	te.vars["PROWL_APPLICATION"] = "from env"
+	te.vars["PROWL_API_KEYS"] = apiKey + "," + otherAPIKey
+	if code := te.run("send", "-event", "Event"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	last, _ = srv.LastAdd()
+	if last.Params.Get("application") != "from env" || len(last.APIKeys()) != 2 {
+		t.Errorf("unexpected request %v", last.Params)
+	}
+	if code := te.run("send", "-event", "Event", "-application", "from flag", "-keys", otherAPIKey); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	last, _ = srv.LastAdd()
+	if last.Params.Get("application") != "from flag" || last.Params.Get("apikey") != otherAPIKey {
+		t.Errorf("unexpected request %v", last.Params)
+	}
+
+	//description from stdin
+	te.stdin = strings.NewReader("line 1\nline 2\n")
+	if code := te.run("send", "-event", "Event", "-stdin", "-v"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	last, _ = srv.LastAdd()
+	if last.Params.Get("description") != "line 1\nline 2" {
+		t.Errorf("unexpected description %q", last.Params.Get("description"))
+	}
+	if !strings.Contains(te.stdout.String(), "remaining") {
+		t.Error("remaining api calls not reported")
+	}
+
+	//only to the named recipients
+	if code := te.run("send", "-event", "Event", "-to", "ops"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	last, _ = srv.LastAdd()
+	if last.Params.Get("apikey") != otherAPIKey {
+		t.Errorf("unexpected request %v", last.Params)
+	}
+	srv.Reset()
+	if code := te.run("send", "-event", "Event", "-to", "primary"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	if last, _ = srv.LastAdd(); last.Params.Get("apikey") != otherAPIKey {
+		t.Errorf("unexpected request %v", last.Params)
+	}
+	if code := te.run("send", "-event", "Event", "-topic", "db"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	if last, _ = srv.LastAdd(); last.Params.Get("apikey") != otherAPIKey {
+		t.Errorf("unexpected request %v", last.Params)
+	}
+	if code := te.run("send", "-event", "Event", "-to", "dev"); code != exitUsage {
+		t.Errorf("unknown recipient should be a usage error, got %d", code)
+	}
+}
+
+func TestSendExitCodes(t *testing.T) {
+	srv := prowltest.NewServer()
+	defer srv.Close()
+
+	te := newTestEnv(t, srv, `{"api_keys": ["`+apiKey+`"], "base_url": "BASEURL"}`)
+
+	tests := []struct {
+		name  string
+		setup func()
+		args  []string
+		code  int
+	}{
+		{"no command", nil, []string{}, exitUsage},
+		{"unknown command", nil, []string{"fly"}, exitUsage},
+		{"unknown flag", nil, []string{"send", "-fly"}, exitUsage},
+		{"no event", nil, []string{"send"}, exitUsage},
+		{"illegal priority", nil, []string{"send", "-event", "E", "-priority", "3"}, exitUsage},
+		{"illegal api key", nil, []string{"send", "-event", "E", "-keys", "short"}, exitUsage},
+		{"stdin and description", nil, []string{"send", "-event", "E", "-description", "D", "-stdin"}, exitUsage},
+		{"rejected", func() { srv.SetAcceptAPIKeys(false) }, []string{"send", "-event", "E"}, exitAuth},
+		{"quota", func() { srv.SetQuotaExhausted(true) }, []string{"send", "-event", "E"}, exitQuota},
+		{"offline", func() { srv.SetOffline(true) }, []string{"send", "-event", "E"}, exitTransport},
+		{"server error", func() { srv.SetInternalError(true) }, []string{"send", "-event", "E"}, exitFailure},
+		{"ok", nil, []string{"send", "-event", "E", "-priority", "-2"}, exitOK},
+	}
+	for _, test := range tests {
+		srv.Reset()
+		if test.setup != nil {
+			test.setup()
+		}
+		if code := te.run(test.args...); code != test.code {
+			t.Errorf("%s: expected exit code %d, got %d: %s", test.name, test.code, code, te.stderr.String())
+		}
+	}
+
+	//an explicitly named config file must exist
+	te.vars["PROWL_CONFIG"] = filepath.Join(te.dir, "missing.json")
+	if code := te.run("send", "-event", "E"); code != exitFailure {
+		t.Errorf("missing config file should fail, got %d", code)
+	}
+}
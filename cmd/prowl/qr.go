@@ -0,0 +1,458 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// This file holds a minimal QR code encoder. It only knows what is needed to show an approve
+// URL in the terminal: byte mode, error correction level M and versions 1 to 10, which hold up
+// to 213 bytes.
+
+// qrBlocks describes the error correction blocks of a version at level M.
+type qrBlocks struct {
+	//ecLen is the number of error correction codewords per block.
+	ecLen int
+	//count1 blocks hold data1 data codewords, count2 blocks hold data1+1.
+	count1, data1, count2 int
+}
+
+// qrVersions are the blocks of the versions 1 to 10 at level M.
+var qrVersions = []qrBlocks{
+	{10, 1, 16, 0},
+	{16, 1, 28, 0},
+	{26, 1, 44, 0},
+	{18, 2, 32, 0},
+	{24, 2, 43, 0},
+	{16, 4, 27, 0},
+	{18, 4, 31, 0},
+	{22, 2, 38, 2},
+	{22, 3, 36, 2},
+	{26, 4, 43, 1},
+}
+
+// qrAlignment are the centers of the alignment patterns of the versions 1 to 10.
+var qrAlignment = [][]int{
+	nil,
+	{6, 18},
+	{6, 22},
+	{6, 26},
+	{6, 30},
+	{6, 34},
+	{6, 22, 38},
+	{6, 24, 42},
+	{6, 26, 46},
+	{6, 28, 50},
+}
+
+// qrCode is a QR code. modules[y][x] is true for dark modules.
+type qrCode struct {
+	version  int
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// encodeQR encodes the text as the smallest QR code it fits in.
+func encodeQR(text string) (*qrCode, error) {
+	for v := 1; v <= len(qrVersions); v++ {
+		b := qrVersions[v-1]
+		capacity := b.count1*b.data1 + b.count2*(b.data1+1)
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(text) > 8*capacity {
+			continue
+		}
+		qr := newQRCode(v)
+		qr.drawCodewords(qrCodewords(qrData([]byte(text), countBits, capacity), b))
+		qr.applyBestMask()
+		return qr, nil
+	}
+	return nil, errors.New("text too long for a QR code")
+}
+
+// qrData encodes the bytes in byte mode and pads them to the capacity of the version.
+func qrData(text []byte, countBits int, capacity int) []byte {
+	var bits []bool
+	put := func(v int, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, v>>uint(i)&1 == 1)
+		}
+	}
+	put(4, 4)
+	put(len(text), countBits)
+	for _, c := range text {
+		put(int(c), 8)
+	}
+	for i := 0; i < 4 && len(bits) < 8*capacity; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+
+	data := make([]byte, 0, capacity)
+	for i := 0; i < len(bits); i += 8 {
+		var c byte
+		for _, bit := range bits[i : i+8] {
+			c <<= 1
+			if bit {
+				c |= 1
+			}
+		}
+		data = append(data, c)
+	}
+	for pad := byte(0xEC); len(data) < capacity; pad ^= 0xEC ^ 0x11 {
+		data = append(data, pad)
+	}
+	return data
+}
+
+// qrCodewords splits the data into blocks, adds the error correction codewords and
+// interleaves the blocks.
+func qrCodewords(data []byte, b qrBlocks) []byte {
+	var blocks, ecs [][]byte
+	for i := 0; i < b.count1+b.count2; i++ {
+		n := b.data1
+		if i >= b.count1 {
+			n++
+		}
+		blocks = append(blocks, data[:n])
+		ecs = append(ecs, reedSolomon(data[:n], b.ecLen))
+		data = data[n:]
+	}
+
+	var out []byte
+	for i := 0; i <= b.data1; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < b.ecLen; i++ {
+		for _, ec := range ecs {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(256) with the QR code polynomial x^8+x^4+x^3+x^2+1.
+func gfMul(x byte, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		hi := z & 0x80
+		z <<= 1
+		if hi != 0 {
+			z ^= 0x1D
+		}
+		if y>>uint(i)&1 == 1 {
+			z ^= x
+		}
+	}
+	return z
+}
+
+// reedSolomon returns the n error correction codewords of the data.
+func reedSolomon(data []byte, n int) []byte {
+	//generator polynomial (x-a^0)(x-a^1)...(x-a^(n-1)) without its leading coefficient
+	gen := make([]byte, n)
+	gen[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < n {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+
+	rem := make([]byte, n)
+	for _, c := range data {
+		factor := c ^ rem[0]
+		copy(rem, rem[1:])
+		rem[n-1] = 0
+		for j := range rem {
+			rem[j] ^= gfMul(gen[j], factor)
+		}
+	}
+	return rem
+}
+
+// newQRCode creates a QR code with the function patterns of the version drawn.
+func newQRCode(version int) *qrCode {
+	size := 17 + 4*version
+	qr := &qrCode{version: version, size: size}
+	for i := 0; i < size; i++ {
+		qr.modules = append(qr.modules, make([]bool, size))
+		qr.function = append(qr.function, make([]bool, size))
+	}
+
+	for i := 0; i < size; i++ {
+		qr.set(6, i, i%2 == 0)
+		qr.set(i, 6, i%2 == 0)
+	}
+	qr.drawFinder(3, 3)
+	qr.drawFinder(size-4, 3)
+	qr.drawFinder(3, size-4)
+
+	centers := qrAlignment[version-1]
+	last := len(centers) - 1
+	for i, x := range centers {
+		for j, y := range centers {
+			//skip the corners of the finder patterns
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	//reserve the format areas, the mask is not known yet
+	qr.drawFormat(0)
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1F25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			a, b := size-11+i%3, i/3
+			qr.set(a, b, bits>>uint(i)&1 == 1)
+			qr.set(b, a, bits>>uint(i)&1 == 1)
+		}
+	}
+	return qr
+}
+
+// set sets a module of a function pattern.
+func (qr *qrCode) set(x int, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+// drawFinder draws a finder pattern and its separator around the center.
+func (qr *qrCode) drawFinder(x int, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= qr.size || yy < 0 || yy >= qr.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			qr.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information for level M and the mask.
+func (qr *qrCode) drawFormat(mask int) {
+	//level M is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		qr.set(8, i, bit(i))
+	}
+	qr.set(8, 7, bit(6))
+	qr.set(8, 8, bit(7))
+	qr.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		qr.set(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.set(8, qr.size-15+i, bit(i))
+	}
+	qr.set(8, qr.size-8, true)
+}
+
+// drawCodewords places the codewords in the zigzag order of the QR code.
+func (qr *qrCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < qr.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if qr.function[y][x] {
+					continue
+				}
+				//the remainder bits stay light
+				if i < 8*len(codewords) {
+					qr.modules[y][x] = codewords[i/8]>>uint(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// qrMasks are the eight data masks. A module is inverted if the mask returns true.
+var qrMasks = []func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// applyMask inverts the data modules selected by the mask. Applying it twice undoes it.
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if !qr.function[y][x] && qrMasks[mask](x, y) {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask applies the mask with the lowest penalty.
+func (qr *qrCode) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := range qrMasks {
+		qr.applyMask(mask)
+		qr.drawFormat(mask)
+		if p := qr.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		qr.applyMask(mask)
+	}
+	qr.applyMask(best)
+	qr.drawFormat(best)
+}
+
+// penalty rates how hard the code is to read for a scanner.
+func (qr *qrCode) penalty() int {
+	p := 0
+	dark := 0
+	finder := []bool{true, false, true, true, true, false, true}
+	for i := 0; i < qr.size; i++ {
+		for _, line := range [2]func(j int) bool{
+			func(j int) bool { return qr.modules[i][j] },
+			func(j int) bool { return qr.modules[j][i] },
+		} {
+			//runs of five or more modules of the same color
+			run := 1
+			for j := 1; j <= qr.size; j++ {
+				if j < qr.size && line(j) == line(j-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			//patterns looking like finder patterns with four light modules next to them
+			for j := 0; j+7 <= qr.size; j++ {
+				match := true
+				for k, f := range finder {
+					if line(j+k) != f {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				light := func(from, to int) bool {
+					for k := from; k < to; k++ {
+						if k >= 0 && k < qr.size && line(k) {
+							return false
+						}
+					}
+					return true
+				}
+				if light(j-4, j) || light(j+7, j+11) {
+					p += 40
+				}
+			}
+		}
+		for j := 0; j < qr.size; j++ {
+			if qr.modules[i][j] {
+				dark++
+			}
+			//2x2 blocks of the same color
+			if i+1 < qr.size && j+1 < qr.size {
+				c := qr.modules[i][j]
+				if qr.modules[i][j+1] == c && qr.modules[i+1][j] == c && qr.modules[i+1][j+1] == c {
+					p += 3
+				}
+			}
+		}
+	}
+	//balance of dark and light modules
+	total := qr.size * qr.size
+	p += abs(dark*20-total*10) / total * 10
+	return p
+}
+
+// render draws the QR code with half block characters, two rows per line, surrounded by
+// a quiet zone. Light modules are drawn, so the code reads right on terminals with light
+// text on a dark background.
+func (qr *qrCode) render(w io.Writer) error {
+	const quiet = 2
+	light := func(x, y int) bool {
+		x, y = x-quiet, y-quiet
+		if x < 0 || x >= qr.size || y < 0 || y >= qr.size {
+			return true
+		}
+		return !qr.modules[y][x]
+	}
+
+	var b strings.Builder
+	size := qr.size + 2*quiet
+	for y := 0; y < size; y += 2 {
+		for x := 0; x < size; x++ {
+			top, bottom := light(x, y), y+1 < size && light(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	//HELLO WORLD in version 1 at level M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomon(data, 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected error correction codewords %v", got)
+	}
+}
+
+func TestEncodeQR(t *testing.T) {
+	for _, tc := range []struct {
+		text    string
+		version int
+	}{
+		{"hi", 1},
+		{"https://www.prowlapp.com/retrieve.php?token=0267157cc27a27f99ad23d1f785f0e7897df0d6b", 5},
+		{strings.Repeat("x", 213), 10},
+	} {
+		qr, err := encodeQR(tc.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if qr.version != tc.version {
+			t.Errorf("%d bytes: expected version %d, got %d", len(tc.text), tc.version, qr.version)
+		}
+		if got := decodeQR(t, qr); got != tc.text {
+			t.Errorf("unexpected text %q", got)
+		}
+	}
+
+	if _, err := encodeQR(strings.Repeat("x", 214)); err == nil {
+		t.Error("too long text should produce an error")
+	}
+
+	qr, _ := encodeQR("hi")
+	var b bytes.Buffer
+	if err := qr.render(&b); err != nil {
+		t.Fatal(err)
+	}
+	//two rows per line with a quiet zone of two modules
+	if lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n"); len(lines) != 13 {
+		t.Errorf("unexpected number of lines %d", len(lines))
+	}
+}
+
+// decodeQR reads the text back from the QR code, checking the error correction codewords.
+func decodeQR(t *testing.T, qr *qrCode) string {
+	t.Helper()
+
+	//the mask from the second copy of the format information
+	format := 0
+	for i := 0; i < 8; i++ {
+		if qr.modules[8][qr.size-1-i] {
+			format |= 1 << i
+		}
+	}
+	for i := 8; i < 15; i++ {
+		if qr.modules[qr.size-15+i][8] {
+			format |= 1 << i
+		}
+	}
+	mask := (format ^ 0x5412) >> 10 & 7
+
+	empty := newQRCode(qr.version)
+	var codewords []byte
+	var c byte
+	n := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < qr.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if empty.function[y][x] {
+					continue
+				}
+				c <<= 1
+				if qr.modules[y][x] != qrMasks[mask](x, y) {
+					c |= 1
+				}
+				if n++; n%8 == 0 {
+					codewords = append(codewords, c)
+				}
+			}
+		}
+	}
+
+	b := qrVersions[qr.version-1]
+	blocks := make([][]byte, b.count1+b.count2)
+	for i := 0; i <= b.data1; i++ {
+		for j := range blocks {
+			if i < b.data1 || j >= b.count1 {
+				blocks[j] = append(blocks[j], codewords[0])
+				codewords = codewords[1:]
+			}
+		}
+	}
+	var data []byte
+	for i, block := range blocks {
+		ec := make([]byte, b.ecLen)
+		for k := range ec {
+			ec[k] = codewords[k*len(blocks)+i]
+		}
+		if !bytes.Equal(reedSolomon(block, b.ecLen), ec) {
+			t.Errorf("block %d: error correction codewords don't match", i)
+		}
+		data = append(data, block...)
+	}
+
+	bits := func(pos int, n int) int {
+		v := 0
+		for i := pos; i < pos+n; i++ {
+			v = v<<1 | int(data[i/8]>>uint(7-i%8)&1)
+		}
+		return v
+	}
+	if mode := bits(0, 4); mode != 4 {
+		t.Fatalf("unexpected mode %d", mode)
+	}
+	countBits := 8
+	if qr.version >= 10 {
+		countBits = 16
+	}
+	text := make([]byte, bits(4, countBits))
+	for i := range text {
+		text[i] = byte(bits(4+countBits+8*i, 8))
+	}
+	return string(text)
+}
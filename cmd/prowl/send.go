@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+// send implements the send command.
+func send(args []string, e *env) int {
+	fs := flag.NewFlagSet("prowl send", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+
+	var cf clientFlags
+	cf.register(fs)
+	to := fs.String("to", "", "comma separated `names` of the recipients, groups or rotations from the config file (default all api keys)")
+	topic := fs.String("topic", "", "send to the subscribers of the `topic` from the config file")
+	priority := fs.String("priority", "normal", "`priority` of the notification: very-low, moderate, normal, high, emergency or -2..2")
+	event := fs.String("event", "", "`event` (title) of the notification")
+	description := fs.String("description", "", "`description` (body) of the notification")
+	readStdin := fs.Bool("stdin", false, "read the description from stdin")
+	withURL := fs.String("url", "", "`url` attached to the notification")
+	appendURL := fs.Bool("append-url", false, "append the url to the description")
+	timeout := fs.Duration("timeout", 30*time.Second, "give up after this `duration`")
+	verbose := fs.Bool("v", false, "print the number of remaining api calls")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		return fail(e, usageErrorf("unexpected argument %q", fs.Arg(0)))
+	}
+
+	prio, err := parsePriority(*priority)
+	if err != nil {
+		return fail(e, err)
+	}
+	if *readStdin {
+		if len(*description) > 0 {
+			return fail(e, usageErrorf("-description and -stdin must not be used together"))
+		}
+		buf, err := io.ReadAll(e.stdin)
+		if err != nil {
+			return fail(e, fmt.Errorf("can't read description from stdin: %w", err))
+		}
+		*description = strings.TrimRight(string(buf), "\r\n")
+	}
+	if len(*event) == 0 && len(*description) == 0 {
+		return fail(e, usageErrorf("event or description is required"))
+	}
+
+	config, err := cf.config(e)
+	if err != nil {
+		return fail(e, err)
+	}
+	client, err := prowl.NewClient(config)
+	if err != nil {
+		return fail(e, err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	result, err := client.Send(ctx, prowl.Notification{
+		To:          splitKeys(*to),
+		Topic:       *topic,
+		Priority:    prio,
+		Event:       *event,
+		Description: *description,
+		URL:         *withURL,
+		AppendURL:   *appendURL,
+	})
+	for _, key := range result.Quarantined {
+		fmt.Fprintf(e.stderr, "prowl: api key %s was rejected\n", key)
+	}
+	if err != nil {
+		return fail(e, err)
+	}
+	if *verbose {
+		if result.Status != prowl.StatusSent {
+			fmt.Fprintf(e.stdout, "notification %s, %d api calls remaining\n", result.Status, result.Remaining)
+			return exitOK
+		}
+		fmt.Fprintf(e.stdout, "notification sent to %d api keys, %d api calls remaining\n", len(result.Delivered), result.Remaining)
+	}
+	return exitOK
+}
+
+var priorityNames = map[string]int{
+	"very-low":  prowl.PrioVeryLow,
+	"moderate":  prowl.PrioModerate,
+	"normal":    prowl.PrioNormal,
+	"high":      prowl.PrioHigh,
+	"emergency": prowl.PrioEmergency,
+}
+
+// parsePriority accepts the name or the number of a priority.
+func parsePriority(s string) (int, error) {
+	if prio, ok := priorityNames[strings.ToLower(s)]; ok {
+		return prio, nil
+	}
+	prio, err := strconv.Atoi(s)
+	if err != nil || prio < prowl.PrioVeryLow || prio > prowl.PrioEmergency {
+		return 0, usageErrorf("illegal priority %q", s)
+	}
+	return prio, nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+// timeLayout is the layout of the times in the output of the silence command.
+const timeLayout = "2006-01-02 15:04"
+
+// silence implements the silence command.
+func silence(args []string, e *env) int {
+	if len(args) == 0 {
+		silenceUsage(e.stderr)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "add":
+		return silenceAdd(args[1:], e)
+	case "list":
+		return silenceList(args[1:], e)
+	case "expire":
+		return silenceExpire(args[1:], e)
+	case "help", "-h", "-help", "--help":
+		silenceUsage(e.stdout)
+		return exitOK
+	}
+
+	fmt.Fprintf(e.stderr, "prowl: unknown silence command %q\n", args[0])
+	silenceUsage(e.stderr)
+	return exitUsage
+}
+
+func silenceUsage(w io.Writer) {
+	fmt.Fprint(w, `Usage: prowl silence <command> [flags]
+
+Commands:
+  add       add a silence and print its id
+  list      list the silences
+  expire    end silences right away
+`)
+}
+
+// silenceClient returns a client that manages the silences in the silence file.
+func silenceClient(cf *clientFlags, e *env) (*prowl.Client, error) {
+	config, err := cf.config(e)
+	if err != nil {
+		return nil, err
+	}
+	return prowl.NewClient(prowl.Config{
+		Application: config.Application,
+		SilenceFile: config.SilenceFile,
+	})
+}
+
+// silenceAdd implements the silence add command.
+func silenceAdd(args []string, e *env) int {
+	fs := flag.NewFlagSet("prowl silence add", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+
+	var cf clientFlags
+	cf.register(fs)
+	event := fs.String("event", "", "regular `expression` matching the events to silence (default all events)")
+	application := fs.String("match-application", "", "regular `expression` matching the applications to silence (default all applications)")
+	minPriority := fs.String("min-priority", "very-low", "lowest `priority` to silence")
+	maxPriority := fs.String("max-priority", "emergency", "highest `priority` to silence")
+	start := fs.String("start", "", "start `time` of the silence in RFC 3339 format (default now)")
+	end := fs.String("end", "", "end `time` of the silence in RFC 3339 format (default start + duration)")
+	duration := fs.Duration("duration", time.Hour, "`duration` of the silence")
+	comment := fs.String("comment", "", "`reason` for the silence")
+	createdBy := fs.String("created-by", "", "`name` of the creator (default $USER)")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		return fail(e, usageErrorf("unexpected argument %q", fs.Arg(0)))
+	}
+
+	s := prowl.Silence{
+		Event:       *event,
+		Application: *application,
+		CreatedBy:   first(*createdBy, e.getenv("USER")),
+		Comment:     *comment,
+		Start:       time.Now(),
+	}
+	var err error
+	if s.MinPriority, err = parsePriority(*minPriority); err != nil {
+		return fail(e, err)
+	}
+	if s.MaxPriority, err = parsePriority(*maxPriority); err != nil {
+		return fail(e, err)
+	}
+	if s.MinPriority == prowl.PrioNormal && s.MaxPriority == prowl.PrioNormal {
+		return fail(e, usageErrorf("a silence can't be limited to normal priority"))
+	}
+	if len(*start) > 0 {
+		if s.Start, err = time.Parse(time.RFC3339, *start); err != nil {
+			return fail(e, usageErrorf("illegal start time %q", *start))
+		}
+	}
+	s.End = s.Start.Add(*duration)
+	if len(*end) > 0 {
+		if s.End, err = time.Parse(time.RFC3339, *end); err != nil {
+			return fail(e, usageErrorf("illegal end time %q", *end))
+		}
+	}
+
+	client, err := silenceClient(&cf, e)
+	if err != nil {
+		return fail(e, err)
+	}
+	defer client.Close()
+
+	id, err := client.AddSilence(s)
+	if err != nil {
+		return fail(e, err)
+	}
+	fmt.Fprintln(e.stdout, id)
+	return exitOK
+}
+
+// silenceList implements the silence list command.
+func silenceList(args []string, e *env) int {
+	fs := flag.NewFlagSet("prowl silence list", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+
+	var cf clientFlags
+	cf.register(fs)
+	all := fs.Bool("all", false, "list expired silences too")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		return fail(e, usageErrorf("unexpected argument %q", fs.Arg(0)))
+	}
+
+	client, err := silenceClient(&cf, e)
+	if err != nil {
+		return fail(e, err)
+	}
+	defer client.Close()
+
+	silences, err := client.Silences()
+	if err != nil {
+		return fail(e, err)
+	}
+	now := time.Now()
+	tw := tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATE\tSTART\tEND\tMATCHERS\tCREATED BY\tCOMMENT")
+	for _, s := range silences {
+		state := "active"
+		switch {
+		case !s.End.After(now):
+			state = "expired"
+		case s.Start.After(now):
+			state = "pending"
+		}
+		if state == "expired" && !*all {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, state, s.Start.Local().Format(timeLayout), s.End.Local().Format(timeLayout), matchers(s), s.CreatedBy, s.Comment)
+	}
+	tw.Flush()
+	return exitOK
+}
+
+// matchers describes what a silence matches.
+func matchers(s prowl.Silence) string {
+	var ret []string
+	if len(s.Event) > 0 {
+		ret = append(ret, fmt.Sprintf("event=~%q", s.Event))
+	}
+	if len(s.Application) > 0 {
+		ret = append(ret, fmt.Sprintf("application=~%q", s.Application))
+	}
+	if s.MinPriority > prowl.PrioVeryLow || s.MaxPriority < prowl.PrioEmergency {
+		ret = append(ret, fmt.Sprintf("priority=%d..%d", s.MinPriority, s.MaxPriority))
+	}
+	if len(ret) == 0 {
+		return "all"
+	}
+	return strings.Join(ret, " ")
+}
+
+// silenceExpire implements the silence expire command.
+func silenceExpire(args []string, e *env) int {
+	fs := flag.NewFlagSet("prowl silence expire", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+
+	var cf clientFlags
+	cf.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		return fail(e, usageErrorf("id of the silence to expire is required"))
+	}
+
+	client, err := silenceClient(&cf, e)
+	if err != nil {
+		return fail(e, err)
+	}
+	defer client.Close()
+
+	for _, id := range fs.Args() {
+		if err := client.ExpireSilence(id); err != nil {
+			return fail(e, err)
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tweithoener/prowlgo/prowltest"
+)
+
+func TestSilence(t *testing.T) {
+	srv := prowltest.NewServer()
+	defer srv.Close()
+
+	te := newTestEnv(t, srv, `{"api_keys": ["`+apiKey+`"], "application": "db", "base_url": "BASEURL"}`)
+	te.vars["USER"] = "alice"
+
+	if code := te.run("silence", "add", "-event", "DB.*", "-max-priority", "high", "-duration", "2h", "-comment", "maintenance"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	id := strings.TrimSpace(te.stdout.String())
+	if _, err := os.Stat(filepath.Join(te.dir, "silences.json")); err != nil {
+		t.Errorf("silence file should be next to the config file: %v", err)
+	}
+
+	//matching notifications are silenced, all others sent
+	if code := te.run("send", "-event", "DB backup", "-v"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	if len(srv.Adds()) != 0 || !strings.Contains(te.stdout.String(), "silenced") {
+		t.Errorf("notification should be silenced: %s", te.stdout.String())
+	}
+	if code := te.run("send", "-event", "DB down", "-priority", "emergency"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	if len(srv.Adds()) != 1 {
+		t.Error("emergency should be sent")
+	}
+
+	if code := te.run("silence", "list"); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	list := te.stdout.String()
+	if !strings.Contains(list, id) || !strings.Contains(list, "active") || !strings.Contains(list, `event=~"DB.*" priority=-2..1`) || !strings.Contains(list, "alice") {
+		t.Errorf("unexpected list:\n%s", list)
+	}
+
+	if code := te.run("silence", "expire", id); code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	if code := te.run("silence", "list"); code != exitOK || strings.Contains(te.stdout.String(), id) {
+		t.Errorf("expired silence should not be listed (%d):\n%s", code, te.stdout.String())
+	}
+	if code := te.run("silence", "list", "-all"); code != exitOK || !strings.Contains(te.stdout.String(), "expired") {
+		t.Errorf("expired silence should be listed (%d):\n%s", code, te.stdout.String())
+	}
+}
+
+func TestSilenceExitCodes(t *testing.T) {
+	srv := prowltest.NewServer()
+	defer srv.Close()
+
+	te := newTestEnv(t, srv, `{"base_url": "BASEURL", "silence_file": "`+filepath.Join(t.TempDir(), "s.json")+`"}`)
+	start := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	for _, args := range [][]string{
+		{"silence"},
+		{"silence", "mute"},
+		{"silence", "add", "-event", "("},
+		{"silence", "add", "-min-priority", "high", "-max-priority", "low"},
+		{"silence", "add", "-min-priority", "normal", "-max-priority", "normal"},
+		{"silence", "add", "-start", "tomorrow"},
+		{"silence", "add", "-start", start, "-end", start},
+		{"silence", "expire"},
+		{"silence", "expire", "nope"},
+		{"silence", "list", "extra"},
+	} {
+		if code := te.run(args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+	if code := te.run("silence", "add", "-start", start); code != exitOK {
+		t.Errorf("unexpected exit code %d: %s", code, te.stderr.String())
+	}
+	if code := te.run("silence", "list"); code != exitOK || !strings.Contains(te.stdout.String(), "pending") {
+		t.Errorf("pending silence should be listed (%d):\n%s", code, te.stdout.String())
+	}
+}
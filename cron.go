@@ -0,0 +1,178 @@
+package prowlgo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronDescriptors are the shorthands accepted instead of the five fields of a cron expression.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames   = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	weekdayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// cronSpec is a parsed cron expression. The fields are bit sets of the matching values.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	//domAll and dowAll are true if the day of month or the day of week field is "*".
+	domAll, dowAll bool
+	loc            *time.Location
+}
+
+// parseCron parses a cron expression with the five fields minute, hour, day of month, month
+// and day of week, e.g. "0 9 * * mon-fri". The expression may start with "TZ=" and the name
+// of the time zone it is evaluated in. It defaults to the local time zone.
+func parseCron(expr string) (*cronSpec, error) {
+	c := &cronSpec{loc: time.Local}
+	fields := strings.Fields(expr)
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "TZ=") || strings.HasPrefix(fields[0], "CRON_TZ=")) {
+		name := fields[0][strings.Index(fields[0], "=")+1:]
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %s", name)
+		}
+		c.loc = loc
+		fields = fields[1:]
+	}
+	if len(fields) == 1 {
+		if spec, ok := cronDescriptors[fields[0]]; ok {
+			fields = strings.Fields(spec)
+		}
+	}
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression needs five fields")
+	}
+
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	//7 is sunday, too
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAll, c.dowAll = fields[2] == "*", fields[4] == "*"
+
+	if c.next(time.Now()).IsZero() {
+		return nil, errors.New("cron expression never matches")
+	}
+	return c, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges ("1-5") and steps ("*/15",
+// "1-30/2") into a bit set.
+func parseCronField(field string, low int, high int, names map[string]int) (set uint64, err error) {
+	value := func(s string) (int, error) {
+		if v, ok := names[strings.ToLower(s)]; ok {
+			return v, nil
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v < low || v > high {
+			return 0, fmt.Errorf("illegal value %q", s)
+		}
+		return v, nil
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("illegal step %q", part[i+1:])
+			}
+		}
+		from, to := low, high
+		switch i := strings.Index(rng, "-"); {
+		case rng == "*":
+		case i >= 0:
+			if from, err = value(rng[:i]); err != nil {
+				return 0, err
+			}
+			if to, err = value(rng[i+1:]); err != nil {
+				return 0, err
+			}
+			if from > to {
+				return 0, fmt.Errorf("illegal range %q", rng)
+			}
+		default:
+			if from, err = value(rng); err != nil {
+				return 0, err
+			}
+			to = from
+			if step > 1 {
+				to = high
+			}
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// next returns the first time after the given time the expression matches. It returns the
+// zero time if there is none within the next five years.
+func (c *cronSpec) next(after time.Time) time.Time {
+	t := after.In(c.loc)
+	year, month, day := t.Date()
+	for i := 0; i < 5*366; i++ {
+		date := time.Date(year, month, day+i, 0, 0, 0, 0, c.loc)
+		if !c.matchesDay(date) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if c.hour&(1<<uint(h)) == 0 {
+				continue
+			}
+			for m := 0; m < 60; m++ {
+				if c.minute&(1<<uint(m)) == 0 {
+					continue
+				}
+				//times skipped by daylight saving time are moved forward by time.Date
+				if at := time.Date(date.Year(), date.Month(), date.Day(), h, m, 0, 0, c.loc); at.After(after) {
+					return at
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether the expression matches the day. Like in the classic cron a day
+// matches if either the day of month or the day of week matches unless one of them is "*".
+func (c *cronSpec) matchesDay(date time.Time) bool {
+	if c.month&(1<<uint(date.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<uint(date.Day())) != 0
+	dow := c.dow&(1<<uint(date.Weekday())) != 0
+	if c.domAll || c.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
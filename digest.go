@@ -0,0 +1,206 @@
+package prowlgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDigestInterval = 15 * time.Minute
+	//maxDigestLine is the length a single notification is shortened to in the digest.
+	maxDigestLine = 500
+)
+
+// DigestConfig enables the digest of the client (see Config.Digest). Notifications with a
+// priority up to MaxPriority are not sent right away. They are held and combined into a single
+// summary notification every Interval. The event of the summary reads "12 notices", its
+// description lists the held notifications, one per line, shortened to fit the description.
+// The summary has the highest priority of the notifications it combines. Notifications for
+// different recipients are combined into different summaries.
+//
+// Send reports held notifications with StatusDigested. Held notifications are kept in memory
+// only. Summaries that can't be sent are tried again with the next summary. Close sends the
+// summaries of the notifications held so far.
+type DigestConfig struct {
+	//MaxPriority is the highest priority of the notifications that go into the digest, e.g.
+	//PrioModerate. It must be below PrioHigh. Note that the zero value is PrioNormal.
+	MaxPriority int
+
+	//Interval is the time between two summaries. Defaults to 15 minutes.
+	Interval time.Duration
+}
+
+func (dc *DigestConfig) validate() error {
+	if dc.MaxPriority < PrioVeryLow || dc.MaxPriority >= PrioHigh {
+		return newFieldError("Digest", "max priority must be in the range -2..0")
+	}
+	if dc.Interval < 0 {
+		return newFieldError("Digest", "interval must not be negative")
+	}
+	if dc.Interval == 0 {
+		dc.Interval = defaultDigestInterval
+	}
+	return nil
+}
+
+// digest holds the notifications of a client until the next summary is due.
+type digest struct {
+	clt    *Client
+	config DigestConfig
+
+	mu sync.Mutex
+	//held maps the api keys of the recipients (see targetKey) to their notifications in the
+	//order they were added.
+	held map[string][]notification
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newDigest(clt *Client, config DigestConfig) *digest {
+	dg := &digest{
+		clt:    clt,
+		config: config,
+		held:   make(map[string][]notification),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go dg.run()
+	return dg
+}
+
+// holds reports whether notifications of the given priority go into the digest.
+func (dg *digest) holds(priority int) bool {
+	return priority <= dg.config.MaxPriority
+}
+
+func (dg *digest) add(n notification) {
+	dg.mu.Lock()
+	defer dg.mu.Unlock()
+
+	key := strings.Join(n.APIKeys, ",")
+	dg.held[key] = append(dg.held[key], n)
+}
+
+func (dg *digest) run() {
+	defer close(dg.done)
+
+	ticker := time.NewTicker(dg.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-dg.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		if err := dg.flush(ctx); err != nil {
+			dg.clt.config.Logger.Printf("prowl digest can't be sent: %s", err)
+		}
+		cancel()
+	}
+}
+
+// flush sends the summaries of all held notifications. The notifications of summaries that
+// can't be sent are held again.
+func (dg *digest) flush(ctx context.Context) error {
+	dg.mu.Lock()
+	held := dg.held
+	dg.held = make(map[string][]notification)
+	dg.mu.Unlock()
+
+	var errs []error
+	failed := make(map[string][]notification)
+	for key, ns := range held {
+		result, err := dg.clt.dispatch(ctx, summarize(ns))
+		if err == nil || errors.Is(err, ErrQueued) {
+			continue
+		}
+		errs = append(errs, err)
+		//a summary delivered to some of the recipients is not sent again to avoid duplicates
+		if len(result.Delivered) == 0 {
+			failed[key] = ns
+		}
+	}
+	if len(failed) > 0 {
+		dg.mu.Lock()
+		for key, ns := range failed {
+			dg.held[key] = append(ns, dg.held[key]...)
+		}
+		dg.mu.Unlock()
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return fmt.Errorf("%d summaries can't be sent, first error: %w", len(errs), errs[0])
+}
+
+func (dg *digest) close(ctx context.Context) error {
+	select {
+	case <-dg.stop:
+		return nil
+	default:
+	}
+	close(dg.stop)
+	<-dg.done
+	return dg.flush(ctx)
+}
+
+// summarize combines the notifications into a single one. The notifications must all have the
+// same recipients.
+func summarize(ns []notification) notification {
+	sum := notification{
+		Priority: PrioVeryLow,
+		Event:    fmt.Sprintf("%d notices", len(ns)),
+		APIKeys:  ns[0].APIKeys,
+	}
+	if len(ns) == 1 {
+		sum.Event = "1 notice"
+	}
+
+	var b strings.Builder
+	for i, n := range ns {
+		if n.Priority > sum.Priority {
+			sum.Priority = n.Priority
+		}
+
+		line := n.Event
+		if description := strings.Join(strings.Fields(n.Description), " "); len(description) > 0 {
+			if len(line) > 0 {
+				line += ": "
+			}
+			line += description
+		}
+		if len(line) > maxDigestLine {
+			line = strings.TrimSpace(truncate(line, maxDigestLine-3)) + "..."
+		}
+
+		//always leave room for the line telling how many notifications have been left out
+		more := fmt.Sprintf("... and %d more", len(ns)-i)
+		if b.Len()+len(line)+1+len(more) > 10000 {
+			b.WriteString(more)
+			break
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	sum.Description = strings.TrimSpace(b.String())
+	return sum
+}
+
+// FlushDigest sends the summaries of the notifications held in the digest right away instead
+// of waiting for the next interval (see DigestConfig). It does nothing if the client has no
+// digest.
+func (clt *Client) FlushDigest(ctx context.Context) error {
+	if clt.digest == nil {
+		return nil
+	}
+	return clt.digest.flush(ctx)
+}
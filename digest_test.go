@@ -0,0 +1,161 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestDigest(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	for _, dc := range []prowl.DigestConfig{{MaxPriority: 3}, {MaxPriority: prowl.PrioHigh}, {Interval: -1}} {
+		dc := dc
+		if _, err := prowl.NewClient(prowl.Config{Digest: &dc}); !errors.Is(err, prowl.ErrInvalidArgument) {
+			t.Errorf("invalid digest %+v should produce an error: %v", dc, err)
+		}
+	}
+
+	alice, bob := multipleValidAPIKeys[0], multipleValidAPIKeys[1]
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:    mock.BaseURL(),
+		Recipients: map[string]string{"alice": alice, "bob": bob},
+		Digest:     &prowl.DigestConfig{MaxPriority: prowl.PrioModerate, Interval: 300 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	//low priorities are held, everything else goes out right away
+	for i, prio := range []int{prowl.PrioVeryLow, prowl.PrioModerate, prowl.PrioVeryLow} {
+		result, err := client.Send(context.Background(), prowl.Notification{Priority: prio, Event: fmt.Sprint("Event ", i), Description: "line 1\nline 2"})
+		if err != nil || result.Status != prowl.StatusDigested {
+			t.Errorf("notification should have been digested %+v: %v", result, err)
+		}
+	}
+	if _, err := client.AddTo([]string{"bob"}, prowl.PrioVeryLow, "Backup", ""); err != nil {
+		t.Error(err)
+	}
+	if _, err := client.Add(prowl.PrioNormal, "Important", "Description"); err != nil {
+		t.Error(err)
+	}
+	if n := len(mock.Adds()); n != 1 {
+		t.Errorf("only the important notification should be sent, got %d", n)
+	}
+
+	//one summary per recipient
+	waitFor(t, func() bool { return len(mock.Adds()) == 3 })
+	summaries := make(map[string]string)
+	for _, add := range mock.Adds()[1:] {
+		summaries[add.Params.Get("event")] = add.Params.Get("description")
+		if add.Params.Get("event") == "3 notices" && (add.Params.Get("priority") != "-1" || len(add.APIKeys()) != 2) {
+			t.Errorf("unexpected summary %v", add.Params)
+		}
+	}
+	if summaries["3 notices"] != "Event 0: line 1 line 2\nEvent 1: line 1 line 2\nEvent 2: line 1 line 2" {
+		t.Errorf("unexpected summary %q", summaries["3 notices"])
+	}
+	if summaries["1 notice"] != "Backup" {
+		t.Errorf("unexpected summary %q", summaries["1 notice"])
+	}
+
+	//nothing held, nothing sent
+	<-time.After(400 * time.Millisecond)
+	if n := len(mock.Adds()); n != 3 {
+		t.Errorf("unexpected summaries, got %d notifications", n)
+	}
+}
+
+func TestDigestLimit(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Digest:  &prowl.DigestConfig{MaxPriority: prowl.PrioNormal, Interval: time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 50; i++ {
+		if _, err := client.Add(prowl.PrioNormal, "Event", strings.Repeat("x", 1000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.FlushDigest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	last, _ := mock.LastAdd()
+	description := last.Params.Get("description")
+	if last.Params.Get("event") != "50 notices" || len(description) > 10000 || !strings.HasSuffix(description, "more") {
+		t.Errorf("unexpected summary %s: %d chars ending in %q", last.Params.Get("event"), len(description), description[len(description)-20:])
+	}
+
+	//notifications of summaries that can't be sent are held for the next one
+	if _, err := client.Add(prowl.PrioNormal, "Event", "first"); err != nil {
+		t.Fatal(err)
+	}
+	mock.SetOffline(true)
+	if err := client.FlushDigest(context.Background()); err == nil {
+		t.Error("failed summary should produce an error")
+	}
+	mock.SetOffline(false)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.FlushDigest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); last.Params.Get("description") != "Event: first\nEvent: second" {
+		t.Errorf("unexpected summary %v", last.Params)
+	}
+
+	//the rest is sent when the client is closed
+	if _, err := client.Add(prowl.PrioVeryLow, "Event", "last words"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); last.Params.Get("description") != "Event: last words" {
+		t.Errorf("unexpected summary %v", last.Params)
+	}
+}
+
+func ExampleDigestConfig() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		Application: "prowlgo Example",
+		Digest:      &prowl.DigestConfig{MaxPriority: prowl.PrioModerate, Interval: 30 * time.Minute},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	//Clients with a digest must be closed.
+	defer client.Close()
+
+	result, err := client.Send(context.Background(), prowl.Notification{
+		Priority:    prowl.PrioVeryLow,
+		Event:       "Cron",
+		Description: "cleanup finished",
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(result.Status)
+
+	//output:
+	//digested
+}
@@ -0,0 +1,162 @@
+package prowlgo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The sentinel errors below classify the errors returned by the client. Use errors.Is()
+// to check for them. They match errors reported by the prowl server (see APIError) as well
+// as errors the client detects on its own before contacting the server.
+var (
+	//ErrUnauthorized indicates that the api key or provider key was rejected (prowl error code 401)
+	//or that the client already knows that its api keys are invalid.
+	ErrUnauthorized = errors.New("prowl: not authorized")
+
+	//ErrQuotaExceeded indicates that the api call limit is spent (prowl error code 406).
+	//Check Client.Reset() to find out when new calls will be granted.
+	ErrQuotaExceeded = errors.New("prowl: api call limit exceeded")
+
+	//ErrTokenNotApproved indicates that the user has not yet approved the token retrieved by
+	//RetrieveToken (prowl error code 409).
+	ErrTokenNotApproved = errors.New("prowl: token not approved")
+
+	//ErrInvalidArgument indicates an illegal argument or configuration. Either the client
+	//detected it on its own (see FieldError) or the prowl server rejected the request
+	//(prowl error code 400).
+	ErrInvalidArgument = errors.New("prowl: invalid argument")
+
+	//ErrTransport indicates that the prowl server could not be reached or that the
+	//HTTP exchange with the server failed.
+	ErrTransport = errors.New("prowl: transport error")
+
+	//ErrQueued indicates that the notification could not be delivered right away but was
+	//put into the outbox for later delivery (see OutboxConfig). The error also matches the
+	//reason why the notification was queued, e.g. ErrTransport, ErrQuotaExceeded or ErrReserved.
+	ErrQueued = errors.New("prowl: queued for later delivery")
+
+	//ErrReserved indicates that the notification was not sent because the remaining api calls
+	//are reserved for notifications of higher priority (see BudgetPolicy).
+	ErrReserved = errors.New("prowl: api calls reserved for higher priorities")
+
+	//ErrPaceExceeded indicates that the notification was not sent because sending it now
+	//would exceed the pace of the client (see PacingConfig and PaceError).
+	ErrPaceExceeded = errors.New("prowl: pace exceeded")
+)
+
+// APIError is returned when the prowl server answers a request with an error.
+// Code and Message are taken from the error element of the XML response. If the server
+// did not send a readable XML response Code will be the HTTP status code.
+type APIError struct {
+	//Operation is the request that failed. One of "add", "verify", "retrieve token"
+	//and "retrieve api key".
+	Operation string
+	//Code is the error code reported by the prowl server.
+	Code int
+	//Message is the error message reported by the prowl server.
+	Message string
+	//StatusCode is the HTTP status code of the response.
+	StatusCode int
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("prowl returned error code %d: %s", e.Code, e.Message)
+}
+
+// Is makes the APIError match the sentinel error for its error code.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrInvalidArgument:
+		return e.Code == 400
+	case ErrUnauthorized:
+		return e.Code == 401
+	case ErrQuotaExceeded:
+		return e.Code == 406
+	case ErrTokenNotApproved:
+		return e.Code == 409
+	}
+	return false
+}
+
+// FieldError is returned when an argument or a config field fails validation before
+// any request is sent. It matches ErrInvalidArgument.
+type FieldError struct {
+	//Field is the name of the offending config field or function argument.
+	Field string
+	//Message describes what is wrong with the field.
+	Message string
+}
+
+func newFieldError(field string, format string, args ...interface{}) *FieldError {
+	return &FieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// Is makes the FieldError match ErrInvalidArgument.
+func (e *FieldError) Is(target error) bool {
+	return target == ErrInvalidArgument
+}
+
+// ApprovalTimeoutError is returned by Client.WaitForAPIKey when the context is done before
+// the user approved the token. It matches ErrTokenNotApproved as well as the error of the
+// context (context.DeadlineExceeded or context.Canceled).
+type ApprovalTimeoutError struct {
+	//Attempts is the number of times the api key was requested.
+	Attempts int
+	//Err is the error of the context.
+	Err error
+}
+
+func (e *ApprovalTimeoutError) Error() string {
+	return fmt.Sprintf("token was not approved after %d attempts: %s", e.Attempts, e.Err)
+}
+
+func (e *ApprovalTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes the ApprovalTimeoutError match ErrTokenNotApproved.
+func (e *ApprovalTimeoutError) Is(target error) bool {
+	return target == ErrTokenNotApproved
+}
+
+// transportError wraps errors of the underlying HTTP client. The original error
+// is kept so that e.g. context.DeadlineExceeded can still be detected.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return fmt.Sprintf("HTTP request to prowl server failed: %s", e.err)
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+func (e *transportError) Is(target error) bool {
+	return target == ErrTransport
+}
+
+// queuedError is returned when a notification was put into the outbox.
+type queuedError struct {
+	cause error
+}
+
+func (e *queuedError) Error() string {
+	if e.cause == nil {
+		return "notification queued for later delivery"
+	}
+	return fmt.Sprintf("notification queued for later delivery: %s", e.cause)
+}
+
+func (e *queuedError) Unwrap() error {
+	return e.cause
+}
+
+func (e *queuedError) Is(target error) bool {
+	return target == ErrQueued
+}
@@ -0,0 +1,144 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestErrors(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	//validation errors are field errors
+	_, err := prowl.NewClient(prowl.Config{Token: "12345"})
+	var fieldErr *prowl.FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "Token" {
+		t.Error("invalid token should produce a field error for Token")
+	}
+	if !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Error("field error should match ErrInvalidArgument")
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = client.Add(3, "Event", "Description")
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "priority" {
+		t.Error("invalid priority should produce a field error for priority")
+	}
+
+	//the server reports an internal error
+	mock.SetInternalError(true)
+	_, err = client.Add(prowl.PrioNormal, "Event", "Description")
+	var apiErr *prowl.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatal("internal server error should produce an api error")
+	}
+	if apiErr.Code != 500 || apiErr.StatusCode != 500 || apiErr.Operation != "add" {
+		t.Errorf("unexpected api error %+v", apiErr)
+	}
+	if errors.Is(err, prowl.ErrUnauthorized) || errors.Is(err, prowl.ErrTransport) {
+		t.Error("internal server error should not match other sentinels")
+	}
+
+	//the server rejects the key
+	mock.Reset()
+	mock.SetAcceptAPIKeys(false)
+	_, err = client.Add(prowl.PrioNormal, "Event", "Description")
+	if !errors.Is(err, prowl.ErrUnauthorized) {
+		t.Error("rejected api key should match ErrUnauthorized")
+	}
+	if !errors.As(err, &apiErr) || apiErr.Code != 401 || apiErr.Message != "Invalid API key" {
+		t.Error("rejected api key should produce an api error with code 401")
+	}
+	//and the client remembers it without asking the server
+	_, err = client.Add(prowl.PrioNormal, "Event", "Description")
+	if !errors.Is(err, prowl.ErrUnauthorized) || errors.As(err, &apiErr) {
+		t.Error("known invalid api key should match ErrUnauthorized")
+	}
+
+	//the token is not approved yet
+	mock.Reset()
+	mock.SetTokenApproved(false)
+	client, err = prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		ProviderKey: aValidProviderKey,
+		Token:       "0987609876098760987609876098760987609876",
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = client.RetrieveAPIKey()
+	if !errors.Is(err, prowl.ErrTokenNotApproved) {
+		t.Error("unapproved token should match ErrTokenNotApproved")
+	}
+	if !errors.As(err, &apiErr) || apiErr.Operation != "retrieve api key" || apiErr.StatusCode != 409 {
+		t.Error("unapproved token should produce an api error for retrieve api key")
+	}
+
+	//the api call limit is spent
+	mock.Reset()
+	mock.SetQuotaExhausted(true)
+	_, err = client.Add(prowl.PrioNormal, "Event", "Description")
+	if !errors.Is(err, prowl.ErrQuotaExceeded) {
+		t.Error("exceeded call limit should match ErrQuotaExceeded")
+	}
+	_, err = client.Add(prowl.PrioNormal, "Event", "Description")
+	if !errors.Is(err, prowl.ErrQuotaExceeded) {
+		t.Error("known exceeded call limit should match ErrQuotaExceeded")
+	}
+
+	//the server does not answer in time
+	mock.Reset()
+	mock.SetDelay(1 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = client.VerifyContext(ctx, singleValidAPIKey)
+	if !errors.Is(err, prowl.ErrTransport) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("timeout should match ErrTransport and context.DeadlineExceeded")
+	}
+}
+
+func ExampleAPIError() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		ProviderKey: aValidProviderKey,
+		Token:       "0987609876098760987609876098760987609876",
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	mock.SetTokenApproved(false)
+	defer mock.Reset()
+
+	//The user did not approve the token yet.
+	_, err = client.RetrieveAPIKey()
+
+	//Check the kind of error using the sentinel errors ...
+	if errors.Is(err, prowl.ErrTokenNotApproved) {
+		fmt.Println("not approved yet")
+	}
+
+	//... or get all the details.
+	var apiErr *prowl.APIError
+	if errors.As(err, &apiErr) {
+		fmt.Println(apiErr.Code, apiErr.Message)
+	}
+
+	//output:
+	//not approved yet
+	//409 The user has not approved your access.
+}
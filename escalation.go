@@ -0,0 +1,329 @@
+package prowlgo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultEscalationTimeout = 5 * time.Minute
+	//maxAckURLLength leaves room for the query parameters within the 256 chars of the url.
+	maxAckURLLength = 160
+)
+
+// EscalationConfig enables the escalation of important notifications (see Config.Escalation).
+//
+// Prowl does not tell if a notification has been read. To make sure somebody takes care of an
+// emergency, notifications with PrioHigh or PrioEmergency carry an acknowledgement link as
+// their url. Tapping the link in the prowl app calls the handler returned by Client.AckHandler.
+// If nobody acknowledges the notification within Timeout, it is sent to the next tier of
+// recipients. The escalation of a notification is called an incident. Send reports the id of
+// the incident in Result.Incident.
+//
+// If the notification has a url of its own, the url is appended to the description.
+//
+// If all recipients are in their quiet hours (see QuietHours), the incident starts when the
+// deferred notification is sent and ends right away if the notification is dropped. The
+// notifications sent by the escalation itself are not held back by quiet hours.
+//
+// Incidents are kept in memory only. Closing the client ends all incidents.
+type EscalationConfig struct {
+	//AckURL is the absolute url the handler returned by Client.AckHandler is reachable at from
+	//the devices of the recipients. At most 160 chars.
+	AckURL string
+
+	//Secret is used to sign the acknowledgement links. Links signed by one client are accepted
+	//by every client with the same secret. A random secret is generated if none is defined.
+	//It is part of the config returned by Client.Config. At least 16 chars.
+	Secret string
+
+	//Timeout is the time the recipients have to acknowledge a notification before it is
+	//escalated to the next tier. Defaults to 5 minutes.
+	Timeout time.Duration
+
+	//Tiers lists the recipients, groups or rotations that are notified one tier after the other
+	//if nobody acknowledges the notification. The notification is sent to its own recipients
+	//first.
+	Tiers [][]string
+
+	//Repeat is the number of times the notification is sent again to the last tier before the
+	//incident is given up. Without tiers the notification is sent again to its own recipients
+	//Repeat times but at least once.
+	Repeat int
+}
+
+func (ec *EscalationConfig) validate() error {
+	u, err := url.Parse(ec.AckURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return newFieldError("Escalation", "ack url must be an absolute http(s) url")
+	}
+	if len(ec.AckURL) > maxAckURLLength {
+		return newFieldError("Escalation", "ack url must not exceed %d chars", maxAckURLLength)
+	}
+	if len(ec.Secret) > 0 && len(ec.Secret) < 16 {
+		return newFieldError("Escalation", "secret must be at least 16 chars long")
+	}
+	if ec.Timeout < 0 || ec.Repeat < 0 {
+		return newFieldError("Escalation", "timeout and repeat must not be negative")
+	}
+	if ec.Timeout == 0 {
+		ec.Timeout = defaultEscalationTimeout
+	}
+	tiers := make([][]string, len(ec.Tiers))
+	for i, tier := range ec.Tiers {
+		if len(tier) == 0 {
+			return newFieldError("Escalation", "tier %d must not be empty", i+1)
+		}
+		tiers[i] = append([]string(nil), tier...)
+	}
+	ec.Tiers = tiers
+	if len(ec.Secret) == 0 {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return fmt.Errorf("can't generate escalation secret: %w", err)
+		}
+		ec.Secret = hex.EncodeToString(buf)
+	}
+	return nil
+}
+
+// escalator runs the incidents of a client.
+type escalator struct {
+	clt    *Client
+	config EscalationConfig
+
+	mu        sync.Mutex
+	incidents map[string]*incident
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// incident is an escalated notification.
+type incident struct {
+	n       notification
+	acked   chan struct{}
+	started bool
+}
+
+func newEscalator(clt *Client, config EscalationConfig) *escalator {
+	return &escalator{
+		clt:       clt,
+		config:    config,
+		incidents: make(map[string]*incident),
+		stop:      make(chan struct{}),
+	}
+}
+
+// escalates reports whether notifications with the given priority are escalated.
+func escalates(priority int) bool {
+	return priority >= PrioHigh
+}
+
+// open creates a new incident and puts the acknowledgement link into the notification. The
+// incident starts when the notification has been sent (see settle).
+func (esc *escalator) open(n *notification) (id string, err error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("can't create incident: %w", err)
+	}
+	id = hex.EncodeToString(buf)
+
+	if len(n.URL) > 0 && !strings.HasSuffix(n.Description, n.URL) {
+		n.Description = appendText(n.Description, n.URL)
+	}
+	n.URL = esc.ackLink(id)
+	n.incident = id
+
+	esc.mu.Lock()
+	esc.incidents[id] = &incident{n: *n, acked: make(chan struct{})}
+	esc.mu.Unlock()
+	return id, nil
+}
+
+// ackLink returns the signed acknowledgement link of the incident.
+func (esc *escalator) ackLink(id string) string {
+	sep := "?"
+	if u, _ := url.Parse(esc.config.AckURL); len(u.RawQuery) > 0 {
+		sep = "&"
+	}
+	return esc.config.AckURL + sep + url.Values{"incident": {id}, "sig": {esc.sign(id)}}.Encode()
+}
+
+func (esc *escalator) sign(id string) string {
+	mac := hmac.New(sha256.New, []byte(esc.config.Secret))
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// settle starts the incident once the notification has gone out. A notification deferred by
+// quiet hours carries the incident along and settles it again when it is sent. An incident
+// that has not been started is closed if its notification is invalid or dropped by quiet
+// hours. ok is false if the incident is closed.
+func (esc *escalator) settle(id string, result Result, err error) (ok bool) {
+	switch {
+	case errors.Is(err, ErrInvalidArgument):
+		return !esc.discard(id)
+	case err == nil && result.Status == StatusDropped && len(result.Quiet) > 0:
+		return !esc.discard(id)
+	case result.Status == StatusDeferred:
+		return true
+	}
+	esc.start(id)
+	return true
+}
+
+// start escalates the incident in the background until it is acknowledged. Starting an
+// incident twice is fine.
+func (esc *escalator) start(id string) {
+	esc.mu.Lock()
+	defer esc.mu.Unlock()
+
+	inc, ok := esc.incidents[id]
+	if !ok || inc.started {
+		return
+	}
+	inc.started = true
+	select {
+	case <-esc.stop:
+		delete(esc.incidents, id)
+	default:
+		esc.wg.Add(1)
+		go esc.run(id, inc.acked, inc.n)
+	}
+}
+
+// discard closes the incident unless it has been started. It reports whether the incident
+// was closed.
+func (esc *escalator) discard(id string) bool {
+	esc.mu.Lock()
+	defer esc.mu.Unlock()
+
+	if inc, ok := esc.incidents[id]; ok && !inc.started {
+		delete(esc.incidents, id)
+		return true
+	}
+	return false
+}
+
+func (esc *escalator) run(id string, acked chan struct{}, n notification) {
+	defer esc.wg.Done()
+	defer esc.close(id)
+
+	steps := len(esc.config.Tiers) + esc.config.Repeat
+	if len(esc.config.Tiers) == 0 && esc.config.Repeat == 0 {
+		steps = 1
+	}
+	for step := 0; ; step++ {
+		timer := time.NewTimer(esc.config.Timeout)
+		select {
+		case <-acked:
+			timer.Stop()
+			return
+		case <-esc.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if step == steps {
+			esc.clt.config.Logger.Printf("prowl incident %s was not acknowledged", id)
+			return
+		}
+
+		if len(esc.config.Tiers) > 0 {
+			tier := esc.config.Tiers[len(esc.config.Tiers)-1]
+			if step < len(esc.config.Tiers) {
+				tier = esc.config.Tiers[step]
+			}
+			keys, err := esc.clt.resolveRecipients(tier, "")
+			if err != nil {
+				esc.clt.config.Logger.Printf("prowl incident %s can't be escalated: %s", id, err)
+				continue
+			}
+			if len(keys) == 0 {
+				continue
+			}
+			n.APIKeys = keys
+		}
+
+		//the escalation must reach somebody, so quiet hours are skipped
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		if _, err := esc.clt.forward(ctx, n); err != nil {
+			esc.clt.config.Logger.Printf("prowl incident %s can't be escalated: %s", id, err)
+		}
+		cancel()
+	}
+}
+
+// ack acknowledges the incident. ok is false if the incident is unknown.
+func (esc *escalator) ack(id string) (ok bool) {
+	esc.mu.Lock()
+	defer esc.mu.Unlock()
+
+	inc, ok := esc.incidents[id]
+	if ok {
+		select {
+		case <-inc.acked:
+		default:
+			close(inc.acked)
+		}
+	}
+	return ok
+}
+
+func (esc *escalator) close(id string) {
+	esc.mu.Lock()
+	defer esc.mu.Unlock()
+	delete(esc.incidents, id)
+}
+
+// shutdown ends all incidents.
+func (esc *escalator) shutdown() {
+	esc.mu.Lock()
+	select {
+	case <-esc.stop:
+	default:
+		close(esc.stop)
+	}
+	esc.mu.Unlock()
+	esc.wg.Wait()
+}
+
+// Acknowledge acknowledges an incident (see EscalationConfig). The notification is not
+// escalated any further. Acknowledging an incident twice is fine. An error is returned if
+// the incident is unknown or has already been given up.
+func (clt *Client) Acknowledge(incident string) error {
+	if clt.escalator == nil || !clt.escalator.ack(incident) {
+		return newFieldError("incident", "unknown incident %s", incident)
+	}
+	return nil
+}
+
+// AckHandler returns the http handler behind the acknowledgement links of escalated
+// notifications. It must be reachable at EscalationConfig.AckURL. Requests with an invalid
+// signature are answered with 403 Forbidden, unknown or given up incidents with 410 Gone.
+func (clt *Client) AckHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		esc := clt.escalator
+		id, sig := r.FormValue("incident"), r.FormValue("sig")
+		if esc == nil || len(id) == 0 || !hmac.Equal([]byte(sig), []byte(esc.sign(id))) {
+			http.Error(w, "invalid acknowledgement link", http.StatusForbidden)
+			return
+		}
+		if err := clt.Acknowledge(id); err != nil {
+			http.Error(w, "incident is closed", http.StatusGone)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "acknowledged")
+	})
+}
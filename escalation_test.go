@@ -0,0 +1,231 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestEscalation(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	alice, bob, carol := multipleValidAPIKeys[0], multipleValidAPIKeys[1], multipleValidAPIKeys[2]
+	recipients := map[string]string{"alice": alice, "bob": bob, "carol": carol}
+
+	var client *prowl.Client
+	ack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client.AckHandler().ServeHTTP(w, r)
+	}))
+	defer ack.Close()
+
+	for _, ec := range []prowl.EscalationConfig{
+		{AckURL: "/ack"},
+		{AckURL: ack.URL + "/" + strings.Repeat("a", 200)},
+		{AckURL: ack.URL, Secret: "short"},
+		{AckURL: ack.URL, Timeout: -1},
+		{AckURL: ack.URL, Tiers: [][]string{{}}},
+		{AckURL: ack.URL, Tiers: [][]string{{"dave"}}},
+	} {
+		ec := ec
+		if _, err := prowl.NewClient(prowl.Config{Recipients: recipients, Escalation: &ec}); !errors.Is(err, prowl.ErrInvalidArgument) {
+			t.Errorf("invalid escalation config %+v should produce an error: %v", ec, err)
+		}
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:    mock.BaseURL(),
+		Recipients: recipients,
+		Logger:     log.New(ioutil.Discard, "", 0),
+		Escalation: &prowl.EscalationConfig{
+			AckURL:  ack.URL + "/ack",
+			Timeout: 100 * time.Millisecond,
+			Tiers:   [][]string{{"bob"}, {"carol"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if len(client.Config().Escalation.Secret) == 0 {
+		t.Error("generated secret should be part of the config")
+	}
+
+	//normal notifications are not escalated
+	if _, err := client.AddWithURL(prowl.PrioNormal, "Event", "Description", "http://example.com/", false); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); last.Params.Get("url") != "http://example.com/" {
+		t.Errorf("unexpected url %s", last.Params.Get("url"))
+	}
+
+	//nobody acknowledges: the notification goes to alice, then bob, then carol
+	mock.Reset()
+	res, err := client.Send(context.Background(), prowl.Notification{To: []string{"alice"}, Priority: prowl.PrioEmergency, Event: "Event", Description: "Description", URL: "http://example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Incident) == 0 {
+		t.Fatal("incident expected")
+	}
+	first, _ := mock.LastAdd()
+	if first.Params.Get("description") != "Description http://example.com/" {
+		t.Errorf("url should be appended to the description: %q", first.Params.Get("description"))
+	}
+	link := first.Params.Get("url")
+	if !strings.HasPrefix(link, ack.URL+"/ack?") || !strings.Contains(link, res.Incident) {
+		t.Errorf("unexpected ack link %s", link)
+	}
+	waitFor(t, func() bool { return len(mock.Adds()) == 3 })
+	var keys []string
+	for _, add := range mock.Adds() {
+		keys = append(keys, add.Params.Get("apikey"))
+		if add.Params.Get("url") != link {
+			t.Error("escalated notifications should carry the same ack link")
+		}
+	}
+	if strings.Join(keys, ",") != alice+","+bob+","+carol {
+		t.Errorf("unexpected escalation %v", keys)
+	}
+
+	//the incident is given up after the last tier
+	waitFor(t, func() bool { return client.Acknowledge(res.Incident) != nil })
+	if len(mock.Adds()) != 3 {
+		t.Errorf("unexpected number of notifications %d", len(mock.Adds()))
+	}
+
+	//alice acknowledges: nobody else is bothered
+	mock.Reset()
+	if res, err = client.Send(context.Background(), prowl.Notification{To: []string{"alice"}, Priority: prowl.PrioHigh, Event: "Event"}); err != nil {
+		t.Fatal(err)
+	}
+	last, _ := mock.LastAdd()
+	link = last.Params.Get("url")
+	u, _ := url.Parse(link)
+	q := u.Query()
+	q.Set("sig", strings.Repeat("0", 32))
+	u.RawQuery = q.Encode()
+	if resp, err := http.Get(u.String()); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("forged ack link should be forbidden: %v", resp)
+	}
+	if resp, err := http.Get(link); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("ack link should be accepted: %v", resp)
+	}
+	<-time.After(300 * time.Millisecond)
+	if n := len(mock.Adds()); n != 1 {
+		t.Errorf("acknowledged notification should not be escalated, got %d notifications", n)
+	}
+	if resp, err := http.Get(link); err != nil || resp.StatusCode != http.StatusGone {
+		t.Errorf("closed incident should be gone: %v", resp)
+	}
+	if err := client.Acknowledge("unknown"); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown incident should produce an error: %v", err)
+	}
+
+	//closing the client ends all incidents
+	mock.Reset()
+	if _, err = client.Send(context.Background(), prowl.Notification{Priority: prowl.PrioHigh, Event: "Event"}); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	<-time.After(200 * time.Millisecond)
+	if n := len(mock.Adds()); n != 1 {
+		t.Errorf("closed client should not escalate, got %d notifications", n)
+	}
+}
+
+func TestEscalationQuietHours(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	alice, bob, carol := multipleValidAPIKeys[0], multipleValidAPIKeys[1], multipleValidAPIKeys[2]
+	now := time.Now().UTC()
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:    mock.BaseURL(),
+		Recipients: map[string]string{"alice": alice, "bob": bob, "carol": carol},
+		Logger:     log.New(ioutil.Discard, "", 0),
+		QuietHours: []prowl.QuietHours{
+			{Recipients: []string{"alice"}, Start: now.Add(-time.Hour).Format("15:04:05"), End: now.Add(2 * time.Second).Format("15:04:05"), TimeZone: "UTC", MaxPriority: prowl.PrioHigh},
+			{Recipients: []string{"bob"}, Start: "00:00", End: "00:00", MaxPriority: prowl.PrioHigh},
+			{Recipients: []string{"carol"}, Start: "00:00", End: "00:00", MaxPriority: prowl.PrioHigh, Drop: true},
+		},
+		Escalation: &prowl.EscalationConfig{
+			AckURL:  "http://example.com/ack",
+			Timeout: 100 * time.Millisecond,
+			Tiers:   [][]string{{"bob"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	//the incident starts when alice's quiet hours are over, bob is woken up despite his
+	res, err := client.Send(context.Background(), prowl.Notification{To: []string{"alice"}, Priority: prowl.PrioHigh, Event: "Event"})
+	if err != nil || res.Status != prowl.StatusDeferred || len(res.Incident) == 0 {
+		t.Fatalf("unexpected result %+v: %v", res, err)
+	}
+	<-time.After(300 * time.Millisecond)
+	if n := len(mock.Adds()); n != 0 {
+		t.Errorf("deferred notification should not be escalated, got %d notifications", n)
+	}
+	waitFor(t, func() bool { return len(mock.Adds()) == 2 })
+	if adds := mock.Adds(); !reflect.DeepEqual(adds[0].APIKeys(), []string{alice}) || !reflect.DeepEqual(adds[1].APIKeys(), []string{bob}) {
+		t.Errorf("unexpected escalation %v", adds)
+	}
+
+	//a dropped notification is not escalated
+	mock.Reset()
+	res, err = client.Send(context.Background(), prowl.Notification{To: []string{"carol"}, Priority: prowl.PrioHigh, Event: "Event"})
+	if err != nil || res.Status != prowl.StatusDropped || len(res.Incident) != 0 {
+		t.Errorf("unexpected result %+v: %v", res, err)
+	}
+	<-time.After(300 * time.Millisecond)
+	if n := len(mock.Adds()); n != 0 {
+		t.Errorf("dropped notification should not be escalated, got %d notifications", n)
+	}
+}
+
+func ExampleEscalationConfig() {
+	client, err := prowl.NewBuilder().
+		SetBaseURL(mock.BaseURL()).
+		SetApplication("prowlgo Example").
+		AddRecipient("alice", "e192384beae856efa6dda87d6a00837cf968bd8c").
+		AddRecipient("bob", "e19238423ae856efa6ddadf34a00837cf968bd8c").
+		SetEscalation(prowl.EscalationConfig{
+			AckURL:  "https://alerts.example.com/prowl/ack",
+			Secret:  "change me to something secret",
+			Timeout: 10 * time.Minute,
+			Tiers:   [][]string{{"bob"}},
+		}).
+		Build()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	//Clients with escalation must be closed.
+	defer client.Close()
+
+	//The acknowledgement links point to this handler
+	http.Handle("/prowl/ack", client.AckHandler())
+
+	//Alice gets the notification first. If she does not acknowledge it within 10 minutes, Bob
+	//gets it as well.
+	_, err = client.AddTo([]string{"alice"}, prowl.PrioEmergency, "Database down", "db1 is not responding")
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	//output:
+}
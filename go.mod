@@ -0,0 +1,3 @@
+module github.com/tweithoener/prowlgo
+
+go 1.21
@@ -0,0 +1,144 @@
+package prowlgo
+
+import (
+	"context"
+	"sync"
+)
+
+// Notifier is the interface implemented by Client for sending notifications. Code that sends
+// notifications should depend on a Notifier rather than on *Client. Tests can then hand in a
+// Recorder and check what would have been sent without talking to a prowl server.
+type Notifier interface {
+	Add(priority int, event string, description string) (remaining int, err error)
+	AddContext(ctx context.Context, priority int, event string, description string) (remaining int, err error)
+	AddWithURL(priority int, event string, description string, withURL string, appendURL bool) (remaining int, err error)
+	AddWithURLContext(ctx context.Context, priority int, event string, description string, withURL string, appendURL bool) (remaining int, err error)
+	Send(ctx context.Context, n Notification) (Result, error)
+	Log(prio int, event string, message string)
+	LogSync(prio int, event string, message string)
+}
+
+var _ Notifier = (*Client)(nil)
+var _ Notifier = (*Recorder)(nil)
+
+// Recorded is a notification captured by a Recorder.
+type Recorded struct {
+	//To lists the recipients passed to Send. Empty if the notification goes to all keys.
+	To []string
+	//Topic is the topic passed to Send.
+	Topic       string
+	Priority    int
+	Event       string
+	Description string
+	URL         string
+	AppendURL   bool
+	//DedupKey is the dedup key passed to Send.
+	DedupKey string
+	//Logged is true if the notification was passed to Log or LogSync.
+	Logged bool
+}
+
+// Recorder is a Notifier that does not send anything but records all notifications
+// passed to it. Use it in tests of code that depends on a Notifier. The zero value is
+// ready to use. A Recorder is safe for concurrent use.
+type Recorder struct {
+	mu            sync.Mutex
+	notifications []Recorded
+	err           error
+	remaining     int
+}
+
+// FailWith makes all following Add calls return err. The notifications are recorded
+// nevertheless. Pass nil to make the calls succeed again.
+func (rec *Recorder) FailWith(err error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.err = err
+}
+
+// SetRemaining defines the number of remaining api calls returned by the Add calls.
+func (rec *Recorder) SetRemaining(remaining int) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.remaining = remaining
+}
+
+// Notifications returns all notifications recorded so far in the order they were passed
+// to the recorder.
+func (rec *Recorder) Notifications() []Recorded {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]Recorded{}, rec.notifications...)
+}
+
+// Last returns the notification recorded last. ok is false if nothing was recorded yet.
+func (rec *Recorder) Last() (last Recorded, ok bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.notifications) == 0 {
+		return
+	}
+	return rec.notifications[len(rec.notifications)-1], true
+}
+
+// Find returns all recorded notifications with the given event.
+func (rec *Recorder) Find(event string) (found []Recorded) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for _, n := range rec.notifications {
+		if n.Event == event {
+			found = append(found, n)
+		}
+	}
+	return
+}
+
+// Reset forgets all recorded notifications.
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.notifications = nil
+}
+
+func (rec *Recorder) record(n Recorded) (remaining int, err error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.notifications = append(rec.notifications, n)
+	return rec.remaining, rec.err
+}
+
+// Add records the notification.
+func (rec *Recorder) Add(priority int, event string, description string) (remaining int, err error) {
+	return rec.record(Recorded{Priority: priority, Event: event, Description: description})
+}
+
+// AddContext records the notification.
+func (rec *Recorder) AddContext(ctx context.Context, priority int, event string, description string) (remaining int, err error) {
+	return rec.Add(priority, event, description)
+}
+
+// AddWithURL records the notification.
+func (rec *Recorder) AddWithURL(priority int, event string, description string, withURL string, appendURL bool) (remaining int, err error) {
+	return rec.record(Recorded{Priority: priority, Event: event, Description: description, URL: withURL, AppendURL: appendURL})
+}
+
+// AddWithURLContext records the notification.
+func (rec *Recorder) AddWithURLContext(ctx context.Context, priority int, event string, description string, withURL string, appendURL bool) (remaining int, err error) {
+	return rec.AddWithURL(priority, event, description, withURL, appendURL)
+}
+
+// Send records the notification. The result does not list any delivered api keys.
+func (rec *Recorder) Send(ctx context.Context, n Notification) (result Result, err error) {
+	result.Remaining, err = rec.record(Recorded{To: n.To, Topic: n.Topic, Priority: n.Priority, Event: n.Event, Description: n.Description, URL: n.URL, AppendURL: n.AppendURL, DedupKey: n.DedupKey})
+	return
+}
+
+// Log records the notification.
+func (rec *Recorder) Log(prio int, event string, message string) {
+	rec.record(Recorded{Priority: prio, Event: event, Description: message, Logged: true})
+}
+
+// LogSync records the notification.
+func (rec *Recorder) LogSync(prio int, event string, message string) {
+	rec.Log(prio, event, message)
+}
@@ -0,0 +1,143 @@
+package prowlgo_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+// diskWatcher is some code that sends notifications. It depends on the Notifier
+// interface so it can be tested without a prowl server.
+type diskWatcher struct {
+	notifier prowl.Notifier
+}
+
+func (dw *diskWatcher) check(mount string, free int) {
+	if free < 5 {
+		dw.notifier.AddWithURL(prowl.PrioHigh, "Disk almost full",
+			fmt.Sprintf("%s has %d%% left", mount, free), "https://dashboard.example.com"+mount, false)
+	}
+}
+
+func ExampleRecorder() {
+	rec := &prowl.Recorder{}
+	dw := &diskWatcher{notifier: rec}
+
+	dw.check("/var", 30)
+	dw.check("/home", 3)
+
+	for _, n := range rec.Notifications() {
+		fmt.Println(n.Priority, n.Event, "-", n.Description, n.URL)
+	}
+
+	//output:
+	//1 Disk almost full - /home has 3% left https://dashboard.example.com/home
+}
+
+func TestRecorder(t *testing.T) {
+	rec := &prowl.Recorder{}
+	if _, ok := rec.Last(); ok {
+		t.Error("empty recorder should have no last notification")
+	}
+
+	rec.SetRemaining(17)
+	if remaining, err := rec.Add(prowl.PrioNormal, "A", "first"); err != nil || remaining != 17 {
+		t.Error("add should succeed with the configured remaining calls")
+	}
+	rec.Log(prowl.PrioModerate, "B", "second")
+
+	failure := errors.New("boom")
+	rec.FailWith(failure)
+	if _, err := rec.AddWithURL(prowl.PrioHigh, "A", "third", "http://URL/", true); err != failure {
+		t.Error("add should fail with the configured error")
+	}
+
+	if len(rec.Notifications()) != 3 {
+		t.Error("all notifications should be recorded")
+	}
+	if last, ok := rec.Last(); !ok || last.Description != "third" || last.URL != "http://URL/" || !last.AppendURL {
+		t.Errorf("unexpected last notification %+v", last)
+	}
+	if found := rec.Find("A"); len(found) != 2 {
+		t.Error("two notifications with event A expected")
+	}
+	if found := rec.Find("B"); len(found) != 1 || !found[0].Logged {
+		t.Error("logged notification expected")
+	}
+
+	rec.Reset()
+	if len(rec.Notifications()) != 0 {
+		t.Error("recorder should be empty after reset")
+	}
+
+	//the recorder can be used concurrently
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec.LogSync(prowl.PrioNormal, "C", "concurrent")
+		}()
+	}
+	wg.Wait()
+	if len(rec.Find("C")) != 10 {
+		t.Error("concurrent notifications got lost")
+	}
+}
+
+func TestSlogHandlerAsync(t *testing.T) {
+	rec := &prowl.Recorder{}
+	rec.FailWith(errors.New("prowl is down"))
+
+	buf := &syncBuffer{}
+	logger := slog.New(prowl.NewSlogHandler(slog.NewTextHandler(buf, nil), rec, nil))
+	logger.Error("broken", "id", 7)
+
+	//failures of the background send are reported to the wrapped handler
+	waitFor(t, func() bool { return strings.Contains(buf.String(), "can't send prowl message") })
+	if last, ok := rec.Last(); !ok || last.Event != "broken" || last.Priority != prowl.PrioHigh || last.Description != "id=7" {
+		t.Errorf("unexpected notification %+v", last)
+	}
+
+	//and the background send is bounded by the timeout
+	slow := &slowNotifier{Recorder: &prowl.Recorder{}, delay: time.Second}
+	logger = slog.New(prowl.NewSlogHandler(slog.NewTextHandler(&bytes.Buffer{}, nil), slow, &prowl.SlogHandlerOptions{
+		Timeout: 10 * time.Millisecond,
+	}))
+	logger.Error("slow")
+	waitFor(t, func() bool { return slow.canceled() })
+}
+
+// slowNotifier blocks AddContext until the delay passed or the context is done.
+type slowNotifier struct {
+	*prowl.Recorder
+	delay time.Duration
+	mu    sync.Mutex
+	done  bool
+}
+
+func (sn *slowNotifier) AddContext(ctx context.Context, priority int, event string, description string) (int, error) {
+	select {
+	case <-time.After(sn.delay):
+	case <-ctx.Done():
+		sn.mu.Lock()
+		sn.done = true
+		sn.mu.Unlock()
+		return 0, ctx.Err()
+	}
+	return sn.Recorder.AddContext(ctx, priority, event, description)
+}
+
+func (sn *slowNotifier) canceled() bool {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+	return sn.done
+}
@@ -0,0 +1,234 @@
+package prowlgo
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultShiftDays is the shift length of a rotation that does not define one.
+const defaultShiftDays = 7
+
+// Rotation is an on-call schedule. The members of the rotation take turns in the given
+// order, each for a shift of Days days. The name of a rotation can be used wherever
+// recipient names are accepted (see Notification.To and Config.Topics). A notification
+// sent to a rotation goes to the recipient on call at the time it is sent.
+//
+// Handoffs happen at the local time of Start in the time zone of the rotation. A rotation
+// starting Monday 09:00 in Europe/Berlin hands off every Monday at 09:00 Berlin time, no
+// matter if daylight saving time is in effect or not.
+type Rotation struct {
+	//Members are the names of the recipients taking turns in this order.
+	Members []string
+	//Start is the time of a handoff to the first member. The rotation continues before and
+	//after Start, i.e. the last member is on call during the shift before Start.
+	Start time.Time
+	//Days is the length of a shift in days. Defaults to 7 days (weekly rotation).
+	Days int
+	//TimeZone is the IANA name of the time zone of the rotation, e.g. "Europe/Berlin".
+	//Defaults to the location of Start.
+	TimeZone string
+	//Overrides put somebody else on call for some time. If overrides overlap the one added
+	//last wins.
+	Overrides []Override
+}
+
+// Override puts a recipient on call from Start until End, regardless of the schedule of the
+// rotation. Use it to swap shifts or to cover for vacations.
+type Override struct {
+	//Recipient is the name of the recipient on call.
+	Recipient string
+	Start     time.Time
+	End       time.Time
+}
+
+// rotation is a validated Rotation.
+type rotation struct {
+	Rotation
+	loc *time.Location
+}
+
+// SetRotation adds an on-call rotation to the client or replaces an existing one. The name of
+// the rotation must not be the name of a recipient or a group.
+func (clt *Client) SetRotation(name string, r Rotation) error {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	return clt.setRotation(name, r)
+}
+
+// RemoveRotation removes the rotation, including its subscriptions. Unknown rotations are
+// handled silently.
+func (clt *Client) RemoveRotation(name string) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	delete(clt.rotations, name)
+	clt.topics.removeMember(name)
+}
+
+// Rotations returns the names of all rotations in alphabetical order.
+func (clt *Client) Rotations() (names []string) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	for name := range clt.rotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// AddOverride adds an override to a rotation.
+func (clt *Client) AddOverride(name string, override Override) error {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	r, ok := clt.rotations[name]
+	if !ok {
+		return newFieldError("rotation", "unknown rotation %s", name)
+	}
+	if err := clt.checkOverride(name, override); err != nil {
+		return err
+	}
+	r.Overrides = append(r.Overrides, override)
+	return nil
+}
+
+// OnCall returns the name of the recipient who is on call in the rotation at the given time.
+func (clt *Client) OnCall(name string, at time.Time) (recipient string, err error) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	r, ok := clt.rotations[name]
+	if !ok {
+		return "", newFieldError("rotation", "unknown rotation %s", name)
+	}
+	return r.onCall(at), nil
+}
+
+// setRotation validates and stores a rotation. Must be called with clt.mu held.
+func (clt *Client) setRotation(name string, r Rotation) error {
+	if len(name) == 0 {
+		return newFieldError("Rotations", "rotation name must not be empty")
+	}
+	if _, ok := clt.recipients[name]; ok || clt.groups.has(name) {
+		return newFieldError("Rotations", "rotation %s must not have the name of a recipient or a group", name)
+	}
+	if len(r.Members) == 0 {
+		return newFieldError("Rotations", "rotation %s needs at least one member", name)
+	}
+	for _, member := range r.Members {
+		if _, ok := clt.recipients[member]; !ok {
+			return newFieldError("Rotations", "member %s of rotation %s is not a recipient", member, name)
+		}
+	}
+	if r.Start.IsZero() {
+		return newFieldError("Rotations", "start of rotation %s is required", name)
+	}
+	if r.Days < 0 {
+		return newFieldError("Rotations", "shift length of rotation %s must not be negative", name)
+	}
+	if r.Days == 0 {
+		r.Days = defaultShiftDays
+	}
+	loc := r.Start.Location()
+	if len(r.TimeZone) > 0 {
+		var err error
+		if loc, err = time.LoadLocation(r.TimeZone); err != nil {
+			return newFieldError("Rotations", "unknown time zone %s of rotation %s", r.TimeZone, name)
+		}
+	}
+	for _, o := range r.Overrides {
+		if err := clt.checkOverride(name, o); err != nil {
+			return err
+		}
+	}
+
+	r.Members = append([]string(nil), r.Members...)
+	r.Overrides = append([]Override(nil), r.Overrides...)
+	clt.rotations[name] = &rotation{Rotation: r, loc: loc}
+	return nil
+}
+
+// checkOverride validates an override of the named rotation. Must be called with clt.mu held.
+func (clt *Client) checkOverride(name string, o Override) error {
+	if _, ok := clt.recipients[o.Recipient]; !ok {
+		return newFieldError("Overrides", "recipient %s of override of rotation %s is unknown", o.Recipient, name)
+	}
+	if !o.End.After(o.Start) {
+		return newFieldError("Overrides", "override of rotation %s must end after its start", name)
+	}
+	return nil
+}
+
+// forgetRotationMember removes a recipient from all rotations. Rotations without members are
+// removed. Must be called with clt.mu held.
+func (clt *Client) forgetRotationMember(recipient string) {
+	for name, r := range clt.rotations {
+		members := r.Members[:0]
+		for _, member := range r.Members {
+			if member != recipient {
+				members = append(members, member)
+			}
+		}
+		r.Members = members
+		overrides := r.Overrides[:0]
+		for _, o := range r.Overrides {
+			if o.Recipient != recipient {
+				overrides = append(overrides, o)
+			}
+		}
+		r.Overrides = overrides
+		if len(r.Members) == 0 {
+			delete(clt.rotations, name)
+			clt.topics.removeMember(name)
+		}
+	}
+}
+
+// rotationsConfig returns the rotations in the format of Config.Rotations. Must be called
+// with clt.mu held.
+func (clt *Client) rotationsConfig() map[string]Rotation {
+	if len(clt.rotations) == 0 {
+		return nil
+	}
+	ret := make(map[string]Rotation, len(clt.rotations))
+	for name, r := range clt.rotations {
+		cpy := r.Rotation
+		cpy.Members = append([]string(nil), r.Members...)
+		cpy.Overrides = append([]Override(nil), r.Overrides...)
+		ret[name] = cpy
+	}
+	return ret
+}
+
+// onCall returns the member on call at the given time.
+func (r *rotation) onCall(at time.Time) string {
+	for i := len(r.Overrides) - 1; i >= 0; i-- {
+		o := r.Overrides[i]
+		if !at.Before(o.Start) && at.Before(o.End) {
+			return o.Recipient
+		}
+	}
+
+	//estimate the number of the shift and correct the estimate for daylight saving time
+	shift := int(at.Sub(r.Start) / (time.Duration(r.Days) * 24 * time.Hour))
+	for r.handoff(shift).After(at) {
+		shift--
+	}
+	for !r.handoff(shift + 1).After(at) {
+		shift++
+	}
+
+	i := shift % len(r.Members)
+	if i < 0 {
+		i += len(r.Members)
+	}
+	return r.Members[i]
+}
+
+// handoff returns the start of the given shift.
+func (r *rotation) handoff(shift int) time.Time {
+	s := r.Start.In(r.loc)
+	return time.Date(s.Year(), s.Month(), s.Day()+shift*r.Days, s.Hour(), s.Minute(), s.Second(), s.Nanosecond(), r.loc)
+}
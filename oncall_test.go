@@ -0,0 +1,194 @@
+package prowlgo_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestOnCall(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	alice, bob, carol := multipleValidAPIKeys[0], multipleValidAPIKeys[1], multipleValidAPIKeys[2]
+	recipients := map[string]string{"alice": alice, "bob": bob, "carol": carol}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone data not available")
+	}
+
+	//weekly handoff on Monday 09:00 Berlin time, daylight saving time starts on March 31st
+	weekly := prowl.Rotation{
+		Members:  []string{"alice", "bob", "carol"},
+		Start:    time.Date(2024, 3, 18, 9, 0, 0, 0, berlin),
+		TimeZone: "Europe/Berlin",
+	}
+
+	for _, r := range []prowl.Rotation{
+		{Start: weekly.Start},
+		{Members: []string{"dave"}, Start: weekly.Start},
+		{Members: []string{"alice"}},
+		{Members: []string{"alice"}, Start: weekly.Start, Days: -1},
+		{Members: []string{"alice"}, Start: weekly.Start, TimeZone: "Mars/Olympus_Mons"},
+		{Members: []string{"alice"}, Start: weekly.Start, Overrides: []prowl.Override{{Recipient: "bob", Start: weekly.Start, End: weekly.Start}}},
+	} {
+		if _, err := prowl.NewClient(prowl.Config{Recipients: recipients, Rotations: map[string]prowl.Rotation{"primary": r}}); !errors.Is(err, prowl.ErrInvalidArgument) {
+			t.Errorf("invalid rotation %+v should produce an error: %v", r, err)
+		}
+	}
+	if _, err := prowl.NewClient(prowl.Config{Recipients: recipients, Rotations: map[string]prowl.Rotation{"alice": weekly}}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("rotation with the name of a recipient should produce an error: %v", err)
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:    mock.BaseURL(),
+		Recipients: recipients,
+		Rotations:  map[string]prowl.Rotation{"primary": weekly},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2024, 3, 18, 9, 0, 0, 0, berlin), "alice"},
+		{time.Date(2024, 3, 25, 8, 59, 0, 0, berlin), "alice"},
+		{time.Date(2024, 3, 25, 9, 0, 0, 0, berlin), "bob"},
+		{time.Date(2024, 4, 1, 6, 59, 0, 0, time.UTC), "bob"},
+		{time.Date(2024, 4, 1, 7, 0, 0, 0, time.UTC), "carol"},
+		{time.Date(2024, 4, 8, 9, 0, 0, 0, berlin), "alice"},
+		{time.Date(2024, 3, 17, 9, 0, 0, 0, berlin), "carol"},
+		{time.Date(2023, 3, 20, 9, 0, 0, 0, berlin), "carol"},
+	}
+	for _, test := range tests {
+		if got, err := client.OnCall("primary", test.at); err != nil || got != test.want {
+			t.Errorf("%s: expected %s to be on call, got %s: %v", test.at, test.want, got, err)
+		}
+	}
+	if _, err := client.OnCall("secondary", time.Now()); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown rotation should produce an error: %v", err)
+	}
+
+	//overrides win, the one added last first
+	vacation := prowl.Override{Recipient: "carol", Start: time.Date(2024, 3, 26, 0, 0, 0, 0, berlin), End: time.Date(2024, 3, 28, 0, 0, 0, 0, berlin)}
+	swap := prowl.Override{Recipient: "alice", Start: time.Date(2024, 3, 27, 0, 0, 0, 0, berlin), End: time.Date(2024, 3, 27, 12, 0, 0, 0, berlin)}
+	if err := client.AddOverride("primary", vacation); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddOverride("primary", swap); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddOverride("primary", prowl.Override{Recipient: "dave", Start: swap.Start, End: swap.End}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("override for unknown recipient should produce an error: %v", err)
+	}
+	for at, want := range map[time.Time]string{
+		time.Date(2024, 3, 25, 23, 0, 0, 0, berlin): "bob",
+		time.Date(2024, 3, 26, 0, 0, 0, 0, berlin):  "carol",
+		time.Date(2024, 3, 27, 6, 0, 0, 0, berlin):  "alice",
+		time.Date(2024, 3, 27, 12, 0, 0, 0, berlin): "carol",
+		time.Date(2024, 3, 28, 0, 0, 0, 0, berlin):  "bob",
+	} {
+		if got, _ := client.OnCall("primary", at); got != want {
+			t.Errorf("%s: expected %s to be on call, got %s", at, want, got)
+		}
+	}
+
+	//notifications to the rotation go to whoever is on call right now
+	daily := prowl.Rotation{Members: []string{"bob", "alice"}, Start: time.Now().Add(-1 * time.Hour), Days: 1}
+	if err := client.SetRotation("primary", daily); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AddTo([]string{"primary"}, prowl.PrioEmergency, "Event", "Description"); err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := lastAdd(); key != bob {
+		t.Errorf("unexpected api key %s", key)
+	}
+	if err := client.Subscribe("db", "primary"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AddToTopic("db", prowl.PrioHigh, "Event", "Description"); err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := lastAdd(); key != bob {
+		t.Errorf("unexpected api key %s", key)
+	}
+
+	//rotations survive the round trip through the config
+	copied, err := prowl.NewClient(client.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := copied.OnCall("primary", time.Now()); got != "bob" {
+		t.Errorf("unexpected recipient on call %s", got)
+	}
+
+	//removed recipients leave the rotation, empty rotations are removed
+	client.RemoveRecipient("bob")
+	if got, _ := client.OnCall("primary", time.Now()); got != "alice" {
+		t.Errorf("unexpected recipient on call %s", got)
+	}
+	client.RemoveRecipient("alice")
+	if len(client.Rotations()) != 0 || len(client.Topics()) != 0 {
+		t.Errorf("unexpected rotations %v and topics %v", client.Rotations(), client.Topics())
+	}
+}
+
+func ExampleRotation() {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	client, err := prowl.NewBuilder().
+		SetBaseURL(mock.BaseURL()).
+		AddRecipient("alice", "e192384beae856efa6dda87d6a00837cf968bd8c").
+		AddRecipient("bob", "e19238423ae856efa6ddadf34a00837cf968bd8c").
+		SetRotation("primary", prowl.Rotation{
+			Members:  []string{"alice", "bob"},
+			Start:    time.Date(2024, 1, 1, 9, 0, 0, 0, berlin),
+			TimeZone: "Europe/Berlin",
+		}).
+		Build()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	//Bob covers for Alice on New Year's Eve
+	err = client.AddOverride("primary", prowl.Override{
+		Recipient: "bob",
+		Start:     time.Date(2024, 12, 31, 18, 0, 0, 0, berlin),
+		End:       time.Date(2025, 1, 1, 9, 0, 0, 0, berlin),
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, at := range []time.Time{
+		time.Date(2024, 12, 31, 12, 0, 0, 0, berlin),
+		time.Date(2024, 12, 31, 20, 0, 0, 0, berlin),
+		time.Date(2025, 1, 6, 12, 0, 0, 0, berlin),
+	} {
+		name, _ := client.OnCall("primary", at)
+		fmt.Println(at.Format("2006-01-02 15:04"), name)
+	}
+
+	//Notifications sent to the rotation go to whoever is on call
+	_, err = client.AddTo([]string{"primary"}, prowl.PrioEmergency, "Database down", "db1 is not responding")
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	//output:
+	//2024-12-31 12:00 alice
+	//2024-12-31 20:00 bob
+	//2025-01-06 12:00 bob
+}
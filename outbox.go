@@ -0,0 +1,549 @@
+package prowlgo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOutboxMaxAge        = 24 * time.Hour
+	defaultOutboxRetryInterval = 1 * time.Minute
+	//outboxCloseGrace is the time Close waits for a delivery in flight to finish.
+	outboxCloseGrace = 5 * time.Second
+
+	outboxOpAdd  = "add"
+	outboxOpDone = "done"
+	outboxOpFail = "fail"
+)
+
+// OutboxConfig enables the outbox of a client (see Config.Outbox). With an outbox Add, AddWithURL,
+// Log and LogSync no longer lose notifications when the prowl server can't be reached, answers
+// with a server error or when the api call limit is spent. The notification is put into the
+// outbox instead and the call returns an error matching ErrQueued. The client keeps on trying to
+// deliver the notifications in the outbox in the order they were added until they are delivered
+// or they expire. As long as the outbox is not empty new notifications are queued behind the
+// pending ones. Only notifications held back by the budget of the client (see BudgetPolicy) are
+// overtaken by notifications of higher priority. A notification that was delivered to some of
+// the api keys of the client (see Client.Send) is never queued again as this would notify those
+// devices twice.
+//
+// The outbox is an append-only file. Every change is synced to disk before the call returns, so
+// queued notifications survive a crash or restart of the program. A client opened with the same
+// Path picks up where the previous one left off. Only one client must use a path at a time.
+// Notifications deferred by quiet hours (see QuietHours) are moved to the outbox when the client
+// is closed and delivered once the quiet hours end.
+//
+// Close waits up to five seconds for a delivery in flight to finish. A delivery still running
+// after that is canceled and the notification stays in the outbox. If the request had already
+// reached the prowl server the notification is delivered again by the next client, so delivery
+// from the outbox is at least once.
+type OutboxConfig struct {
+	//Path is the file the outbox is stored in. It will be created if it does not exist.
+	Path string
+
+	//MaxAge is the time after which undelivered notifications are given up.
+	//Defaults to 24 hours.
+	MaxAge time.Duration
+
+	//RetryInterval is the time between two attempts to deliver the pending notifications.
+	//Defaults to one minute.
+	RetryInterval time.Duration
+}
+
+// OutboxStats describes the state of the outbox of a client. See Client.OutboxStats.
+type OutboxStats struct {
+	//Pending is the number of notifications waiting for delivery.
+	Pending int
+	//Delivered is the number of notifications delivered from the outbox since it was opened.
+	Delivered int
+	//Failed is the number of notifications given up since the outbox was opened. Either because
+	//they expired or because the prowl server rejected them.
+	Failed int
+	//Oldest is the time the oldest pending notification was added. Zero if nothing is pending.
+	Oldest time.Time
+	//LastError is the last error that prevented the delivery of a pending notification.
+	LastError string
+}
+
+func (oc *OutboxConfig) validate() error {
+	if len(oc.Path) == 0 {
+		return newFieldError("Outbox", "outbox path must be defined")
+	}
+	if oc.MaxAge < 0 || oc.RetryInterval < 0 {
+		return newFieldError("Outbox", "outbox max age and retry interval must not be negative")
+	}
+	if oc.MaxAge == 0 {
+		oc.MaxAge = defaultOutboxMaxAge
+	}
+	if oc.RetryInterval == 0 {
+		oc.RetryInterval = defaultOutboxRetryInterval
+	}
+	return nil
+}
+
+// outboxRecord is a line in the outbox file.
+type outboxRecord struct {
+	Op           string        `json:"op"`
+	ID           uint64        `json:"id"`
+	Created      int64         `json:"created,omitempty"`
+	Due          int64         `json:"due,omitempty"`
+	Notification *notification `json:"notification,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+type outboxItem struct {
+	id      uint64
+	created time.Time
+	n       notification
+	//due is the time the notification must not be delivered before. See outbox.hold.
+	due time.Time
+	//reserved is true if the last attempt to deliver the notification was refused by the
+	//budget of the client.
+	reserved bool
+}
+
+type outbox struct {
+	clt    *Client
+	config OutboxConfig
+
+	mu        sync.Mutex
+	file      *os.File
+	nextID    uint64
+	pending   []outboxItem
+	delivered int
+	failed    int
+	lastError string
+
+	kick     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func openOutbox(clt *Client, config OutboxConfig) (ob *outbox, err error) {
+	ob = &outbox{
+		clt:    clt,
+		config: config,
+		nextID: 1,
+		kick:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if err = ob.load(); err != nil {
+		return nil, fmt.Errorf("can't load outbox %s: %w", config.Path, err)
+	}
+	if err = ob.compact(); err != nil {
+		return nil, fmt.Errorf("can't compact outbox %s: %w", config.Path, err)
+	}
+
+	go ob.run()
+	ob.trigger()
+
+	return ob, nil
+}
+
+// load replays the outbox file. Lines that can't be decoded are skipped. They are the
+// result of a crash while writing the line.
+func (ob *outbox) load() error {
+	f, err := os.Open(ob.config.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	items := make(map[uint64]outboxItem)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec outboxRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec.ID >= ob.nextID {
+			ob.nextID = rec.ID + 1
+		}
+		switch rec.Op {
+		case outboxOpAdd:
+			if rec.Notification != nil {
+				item := outboxItem{id: rec.ID, created: time.Unix(rec.Created, 0), n: *rec.Notification}
+				if rec.Due > 0 {
+					item.due = time.Unix(rec.Due, 0)
+				}
+				items[rec.ID] = item
+			}
+		case outboxOpDone, outboxOpFail:
+			delete(items, rec.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	for id := uint64(1); id < ob.nextID; id++ {
+		if item, ok := items[id]; ok {
+			ob.pending = append(ob.pending, item)
+		}
+	}
+	return nil
+}
+
+// compact replaces the outbox file by a file that only holds the pending notifications.
+// The new file is written next to the old one and then renamed to make this crash-safe.
+// Must be called with ob.mu held (or before the outbox is in use).
+func (ob *outbox) compact() error {
+	if ob.file != nil {
+		if err := ob.file.Close(); err != nil {
+			return err
+		}
+		ob.file = nil
+	}
+
+	tmp := ob.config.Path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, item := range ob.pending {
+		if err = writeOutboxRecord(w, item.addRecord()); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, ob.config.Path); err != nil {
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(ob.config.Path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	ob.file, err = os.OpenFile(ob.config.Path, os.O_APPEND|os.O_WRONLY, 0600)
+	return err
+}
+
+func (item outboxItem) addRecord() outboxRecord {
+	n := item.n
+	rec := outboxRecord{Op: outboxOpAdd, ID: item.id, Created: item.created.Unix(), Notification: &n}
+	if !item.due.IsZero() {
+		rec.Due = item.due.Unix()
+	}
+	return rec
+}
+
+// expired reports whether the notification is given up. The age of notifications held until
+// a due time counts from that time.
+func (item outboxItem) expired(now time.Time, maxAge time.Duration) bool {
+	since := item.created
+	if item.due.After(since) {
+		since = item.due
+	}
+	return now.Sub(since) > maxAge
+}
+
+func writeOutboxRecord(w io.Writer, rec outboxRecord) error {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(buf, '\n'))
+	return err
+}
+
+// append writes the record to the outbox file and syncs it to disk.
+// Must be called with ob.mu held.
+func (ob *outbox) append(rec outboxRecord) error {
+	if ob.file == nil {
+		return fmt.Errorf("outbox is closed")
+	}
+	if err := writeOutboxRecord(ob.file, rec); err != nil {
+		return err
+	}
+	return ob.file.Sync()
+}
+
+// add sends the notification right away if nothing is pending. It is put into the outbox if
+// there are pending notifications or if sending fails for a reason that might go away.
+// Pending notifications held back by the budget of the client don't hold back notifications
+// of higher priority.
+func (ob *outbox) add(ctx context.Context, n notification) (result Result, err error) {
+	ob.mu.Lock()
+	wait := false
+	now := time.Now()
+	for _, item := range ob.pending {
+		if item.due.After(now) {
+			continue
+		}
+		if !item.reserved || item.n.Priority >= n.Priority {
+			wait = true
+			break
+		}
+	}
+	ob.mu.Unlock()
+
+	if !wait {
+		result, err = ob.clt.send(ctx, n)
+		//a notification delivered to some of the keys is not queued again to avoid duplicates
+		if err == nil || !ob.deferrable(err) || len(result.Delivered) > 0 {
+			return
+		}
+	} else {
+		ob.clt.mu.Lock()
+		remaining, reset := ob.clt.remaining, ob.clt.reset
+		_, apiKeys := ob.clt.targetAPIKeys(n.APIKeys)
+		ob.clt.mu.Unlock()
+		calls := len(splitAPIKeys(apiKeys, maxAPIKeysPerRequest))
+		if err = checkBudget(ob.clt.config.Budget, n.Priority, calls, remaining, reset); err != nil && !ob.deferrable(err) {
+			return Result{Remaining: remaining, Status: StatusDropped}, err
+		}
+	}
+
+	result = Result{Remaining: ob.clt.remainingCalls(), Quarantined: result.Quarantined, Status: StatusQueued}
+	if qerr := ob.enqueue(n, err); qerr != nil {
+		if err == nil {
+			return result, fmt.Errorf("can't queue notification: %w", qerr)
+		}
+		return result, fmt.Errorf("%w (can't queue notification: %s)", err, qerr)
+	}
+	return result, &queuedError{cause: err}
+}
+
+func (ob *outbox) enqueue(n notification, cause error) error {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	if cause != nil {
+		ob.lastError = cause.Error()
+	}
+	return ob.put(outboxItem{created: time.Now(), n: n, reserved: errors.Is(cause, ErrReserved)})
+}
+
+// hold puts the notification into the outbox to be delivered once it is due. It does not hold
+// back other notifications until then.
+func (ob *outbox) hold(n notification, due time.Time) error {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	return ob.put(outboxItem{created: time.Now(), n: n, due: due})
+}
+
+// put assigns an id to the item and appends it to the pending notifications. Must be called
+// with ob.mu held.
+func (ob *outbox) put(item outboxItem) error {
+	item.id = ob.nextID
+	if err := ob.append(item.addRecord()); err != nil {
+		return err
+	}
+	ob.nextID++
+	ob.pending = append(ob.pending, item)
+	ob.trigger()
+	return nil
+}
+
+// deferrable reports whether a failed notification should be queued for later delivery.
+func (ob *outbox) deferrable(err error) bool {
+	if errors.Is(err, ErrReserved) {
+		return ob.clt.config.Budget != nil && ob.clt.config.Budget.Queue
+	}
+	return errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrPaceExceeded) || DefaultRetryable(err)
+}
+
+func (ob *outbox) trigger() {
+	select {
+	case ob.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (ob *outbox) run() {
+	defer close(ob.done)
+
+	//a delivery in flight gets some time to finish when the outbox is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-ob.stop
+		select {
+		case <-time.After(outboxCloseGrace):
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(ob.config.RetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ob.stop:
+			return
+		case <-ob.kick:
+		case <-ticker.C:
+		}
+		ob.deliver(ctx)
+	}
+}
+
+// deliver sends the pending notifications in order. It stops at the first notification
+// that can't be delivered for now or when the outbox is closed. Notifications held back by the
+// budget of the client and notifications that are not due yet are skipped.
+func (ob *outbox) deliver(ctx context.Context) {
+	for skip := 0; ctx.Err() == nil && !ob.stopped(); {
+		ob.mu.Lock()
+		if len(ob.pending) <= skip {
+			ob.mu.Unlock()
+			return
+		}
+		item := ob.pending[skip]
+		ob.mu.Unlock()
+
+		now := time.Now()
+		if item.due.After(now) {
+			skip++
+			continue
+		}
+		if item.expired(now, ob.config.MaxAge) {
+			ob.finish(item, outboxOpFail, "expired")
+			continue
+		}
+
+		sctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+		result, err := ob.clt.send(sctx, item.n)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, ErrReserved) && ob.deferrable(err) {
+			ob.setReserved(item.id)
+			skip++
+			continue
+		}
+		if err != nil && ob.deferrable(err) && len(result.Delivered) == 0 {
+			ob.mu.Lock()
+			ob.lastError = err.Error()
+			ob.mu.Unlock()
+			return
+		}
+		if err != nil {
+			ob.finish(item, outboxOpFail, err.Error())
+			continue
+		}
+		ob.finish(item, outboxOpDone, "")
+	}
+}
+
+// stopped reports whether the outbox is being closed.
+func (ob *outbox) stopped() bool {
+	select {
+	case <-ob.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ob *outbox) finish(item outboxItem, op string, reason string) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	if err := ob.append(outboxRecord{Op: op, ID: item.id, Error: reason}); err != nil {
+		ob.lastError = err.Error()
+	}
+	for i := range ob.pending {
+		if ob.pending[i].id == item.id {
+			ob.pending = append(ob.pending[:i:i], ob.pending[i+1:]...)
+			break
+		}
+	}
+	if op == outboxOpDone {
+		ob.delivered++
+	} else {
+		ob.failed++
+		ob.lastError = reason
+	}
+
+	//start over with an empty file once everything is out
+	if len(ob.pending) == 0 {
+		if err := ob.compact(); err != nil {
+			ob.lastError = err.Error()
+		}
+	}
+}
+
+// setReserved marks the pending notification as held back by the budget.
+func (ob *outbox) setReserved(id uint64) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	for i := range ob.pending {
+		if ob.pending[i].id == id {
+			ob.pending[i].reserved = true
+			ob.lastError = "api calls reserved for higher priorities"
+		}
+	}
+}
+
+func (ob *outbox) stats() OutboxStats {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	stats := OutboxStats{
+		Pending:   len(ob.pending),
+		Delivered: ob.delivered,
+		Failed:    ob.failed,
+		LastError: ob.lastError,
+	}
+	if len(ob.pending) > 0 {
+		stats.Oldest = ob.pending[0].created
+	}
+	return stats
+}
+
+// close stops the delivery of the pending notifications and closes the outbox file. It is
+// safe to call close more than once and even if the file could not be reopened by compact.
+func (ob *outbox) close() error {
+	ob.stopOnce.Do(func() { close(ob.stop) })
+	<-ob.done
+
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	if ob.file == nil {
+		return nil
+	}
+	err := ob.file.Close()
+	ob.file = nil
+	return err
+}
+
+// OutboxStats returns the state of the outbox of this client. All counts are zero if the
+// client has no outbox.
+func (clt *Client) OutboxStats() OutboxStats {
+	if clt.outbox == nil {
+		return OutboxStats{}
+	}
+	return clt.outbox.stats()
+}
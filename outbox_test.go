@@ -0,0 +1,247 @@
+package prowlgo_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestOutbox(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	dir, err := ioutil.TempDir("", "prowlgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "outbox")
+
+	if _, err := prowl.NewClient(prowl.Config{Outbox: &prowl.OutboxConfig{}}); err == nil {
+		t.Error("outbox without path should produce an error")
+	}
+	if _, err := prowl.NewClient(prowl.Config{Outbox: &prowl.OutboxConfig{Path: path, MaxAge: -1}}); err == nil {
+		t.Error("negative max age should produce an error")
+	}
+
+	config := prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Outbox:  &prowl.OutboxConfig{Path: path, RetryInterval: 50 * time.Millisecond},
+	}
+	client, err := prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//as long as the server is fine notifications are sent right away
+	if _, err := client.Add(prowl.PrioNormal, "Event", "0"); err != nil {
+		t.Error(err)
+	}
+
+	//the server is down: notifications are queued
+	mock.SetOffline(true)
+	for i := 1; i <= 3; i++ {
+		_, err := client.Add(prowl.PrioNormal, "Event", fmt.Sprint(i))
+		if !errors.Is(err, prowl.ErrQueued) {
+			t.Errorf("notification should have been queued: %v", err)
+		}
+		if i == 1 && !errors.Is(err, prowl.ErrTransport) {
+			t.Error("queued error should match its cause")
+		}
+	}
+	stats := client.OutboxStats()
+	if stats.Pending != 3 || stats.Oldest.IsZero() {
+		t.Errorf("unexpected outbox stats %+v", stats)
+	}
+
+	//illegal arguments are never queued
+	if _, err := client.Add(3, "Event", "Description"); errors.Is(err, prowl.ErrQueued) {
+		t.Error("invalid notification should not be queued")
+	}
+
+	//restart the program
+	if err := client.Close(); err != nil {
+		t.Error(err)
+	}
+	mock.Reset()
+	client, err = prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	waitFor(t, func() bool { return client.OutboxStats().Pending == 0 })
+	if stats := client.OutboxStats(); stats.Delivered != 3 || stats.Failed != 0 {
+		t.Errorf("unexpected outbox stats %+v", stats)
+	}
+	if descr := addedDescriptions(); !reflect.DeepEqual(descr, []string{"1", "2", "3"}) {
+		t.Errorf("notifications not delivered in order: %v", descr)
+	}
+
+	//the api call limit is spent
+	mock.SetQuotaExhausted(true)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "4"); !errors.Is(err, prowl.ErrQueued) || !errors.Is(err, prowl.ErrQuotaExceeded) {
+		t.Errorf("notification should have been queued: %v", err)
+	}
+	if stats := client.OutboxStats(); stats.Pending != 1 || stats.LastError == "" {
+		t.Errorf("unexpected outbox stats %+v", stats)
+	}
+}
+
+func TestOutboxRecovery(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	dir, err := ioutil.TempDir("", "prowlgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "outbox")
+
+	//an outbox file with a delivered, an expired, a pending and a deferred notification that
+	//is due followed by a line that was not completely written when the program crashed
+	now := time.Now().Unix()
+	content := fmt.Sprintf(`{"op":"add","id":1,"created":%d,"notification":{"priority":0,"event":"E","description":"delivered"}}
+{"op":"add","id":2,"created":%d,"notification":{"priority":0,"event":"E","description":"expired"}}
+{"op":"add","id":3,"created":%d,"notification":{"priority":0,"event":"E","description":"pending"}}
+{"op":"done","id":1}
+{"op":"add","id":4,"created":%d,"due":%d,"notification":{"priority":0,"event":"E","description":"due"}}
+{"op":"add","id":5,"created":%d,"notifi`, now, now-7200, now, now-7200, now-60, now)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Outbox:  &prowl.OutboxConfig{Path: path, MaxAge: 1 * time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	waitFor(t, func() bool { return client.OutboxStats().Pending == 0 })
+	if stats := client.OutboxStats(); stats.Delivered != 2 || stats.Failed != 1 || stats.LastError != "expired" {
+		t.Errorf("unexpected outbox stats %+v", stats)
+	}
+	if descr := addedDescriptions(); !reflect.DeepEqual(descr, []string{"pending", "due"}) {
+		t.Errorf("unexpected notifications delivered: %v", descr)
+	}
+
+	//once everything is delivered the file is empty again
+	if fi, err := os.Stat(path); err != nil || fi.Size() != 0 {
+		t.Error("outbox file should be empty")
+	}
+}
+
+func TestOutboxClose(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	path := filepath.Join(t.TempDir(), "outbox")
+	config := prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Outbox:  &prowl.OutboxConfig{Path: path, RetryInterval: 50 * time.Millisecond},
+	}
+	client, err := prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.SetOffline(true)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "in flight"); !errors.Is(err, prowl.ErrQueued) {
+		t.Errorf("notification should have been queued: %v", err)
+	}
+
+	//Close lets the delivery in flight finish
+	mock.Reset()
+	mock.SetDelay(500 * time.Millisecond)
+	<-time.After(200 * time.Millisecond)
+	if err := client.Close(); err != nil {
+		t.Error(err)
+	}
+	if n := len(mock.Adds()); n != 1 {
+		t.Errorf("delivery in flight should have finished, got %d notifications", n)
+	}
+
+	//and it is not delivered again after a restart
+	mock.Reset()
+	client, err = prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := client.OutboxStats(); stats.Pending != 0 {
+		t.Errorf("unexpected outbox stats %+v", stats)
+	}
+
+	//closing concurrently is fine
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func ExampleOutboxConfig() {
+	dir, err := ioutil.TempDir("", "prowlgo")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		Application: "prowlgo Example",
+		Outbox:      &prowl.OutboxConfig{Path: filepath.Join(dir, "outbox")},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	//Clients with an outbox must be closed.
+	defer client.Close()
+
+	_, err = client.Add(prowl.PrioHigh, "Test Event", "Delivered now or later")
+	if errors.Is(err, prowl.ErrQueued) {
+		fmt.Println("will be delivered later")
+	} else if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println("pending:", client.OutboxStats().Pending)
+
+	//output:
+	//pending: 0
+}
+
+// waitFor polls cond until it is true. The test fails if that takes longer than 5 seconds.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+}
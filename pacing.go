@@ -0,0 +1,126 @@
+package prowlgo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+const defaultPacingBurst = 10
+
+// PacingConfig enables the pacing of the client (see Config.Pacing). Without pacing the client
+// sends as fast as it is asked to until the api call limit is spent. With pacing the remaining
+// api calls are spread evenly over the time until the limit is reset.
+//
+// Pacing works like a token bucket. Every add request takes a token from the bucket. The bucket
+// holds up to Burst tokens and is refilled at the rate of the remaining api calls divided by
+// the time until the limit is reset, as last reported by the prowl server. If the bucket is
+// empty, Send either fails with a *PaceError or waits until a token is available.
+type PacingConfig struct {
+	//Burst is the number of add requests that can be sent in a row before pacing kicks in.
+	//Defaults to 10.
+	Burst int
+
+	//Wait makes Send wait for a token instead of failing with a *PaceError. Send still fails
+	//with a *PaceError if the deadline of its context would pass before a token is available.
+	Wait bool
+}
+
+func (pc *PacingConfig) validate() error {
+	if pc.Burst < 0 {
+		return newFieldError("Pacing", "burst must not be negative")
+	}
+	if pc.Burst == 0 {
+		pc.Burst = defaultPacingBurst
+	}
+	return nil
+}
+
+// PaceError is returned by Send if sending the notification now would exceed the pace of the
+// client (see PacingConfig). It matches ErrPaceExceeded.
+type PaceError struct {
+	//Delay is the time until the notification could be sent.
+	Delay time.Duration
+}
+
+func (e *PaceError) Error() string {
+	return fmt.Sprintf("sending now would exceed the pace; try again in %s", e.Delay.Round(time.Millisecond))
+}
+
+// Is makes the PaceError match ErrPaceExceeded.
+func (e *PaceError) Is(target error) bool {
+	return target == ErrPaceExceeded
+}
+
+// pacer is the token bucket of a client. The rate is derived from the remaining api calls and
+// the reset time the client learned from the last response of the prowl server.
+type pacer struct {
+	config PacingConfig
+	tokens float64
+	last   time.Time
+}
+
+func newPacer(config PacingConfig) *pacer {
+	return &pacer{config: config, tokens: float64(config.Burst)}
+}
+
+// take takes the tokens for the given number of requests from the bucket. If there are not
+// enough tokens, nothing is taken and the time until they are available is returned.
+// Must be called with clt.mu held.
+func (p *pacer) take(requests int, remaining int, reset time.Time, now time.Time) (delay time.Duration) {
+	burst := float64(p.config.Burst)
+	rate := math.Inf(1)
+	if left := reset.Sub(now); left > 0 {
+		rate = math.Max(float64(remaining), 0) / left.Seconds()
+	}
+
+	if math.IsInf(rate, 1) {
+		p.tokens = burst
+	} else if !p.last.IsZero() {
+		p.tokens = math.Min(burst, p.tokens+rate*now.Sub(p.last).Seconds())
+	}
+	p.last = now
+
+	//more requests than the bucket can hold are sent once it is full
+	need := math.Min(float64(requests), burst)
+	if p.tokens >= need {
+		p.tokens -= float64(requests)
+		return 0
+	}
+	if rate == 0 {
+		return reset.Sub(now)
+	}
+	return time.Duration((need - p.tokens) / rate * float64(time.Second))
+}
+
+// pace returns once the given number of requests can be sent without exceeding the pace of
+// the client.
+func (clt *Client) pace(ctx context.Context, requests int) error {
+	if clt.pacer == nil {
+		return nil
+	}
+	for {
+		clt.mu.Lock()
+		delay := clt.pacer.take(requests, clt.remaining, clt.reset, time.Now())
+		clt.mu.Unlock()
+		if delay <= 0 {
+			return nil
+		}
+
+		perr := &PaceError{Delay: delay}
+		if !clt.pacer.config.Wait {
+			return perr
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return perr
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("waiting for pace: %w", ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestPacing(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	if _, err := prowl.NewClient(prowl.Config{Pacing: &prowl.PacingConfig{Burst: -1}}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("negative burst should produce an error: %v", err)
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Pacing:  &prowl.PacingConfig{Burst: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//10 calls left for the next hour: the burst goes out, then one call every 6 minutes
+	mock.SetRemaining(11)
+	mock.SetResetDate(time.Now().Add(1 * time.Hour))
+	for i := 0; i < 2; i++ {
+		if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requests := len(mock.Requests())
+	result, err := client.Send(context.Background(), prowl.Notification{Event: "Event"})
+	var perr *prowl.PaceError
+	if !errors.Is(err, prowl.ErrPaceExceeded) || !errors.As(err, &perr) || result.Status != prowl.StatusDropped {
+		t.Fatalf("notification should exceed the pace %+v: %v", result, err)
+	}
+	if perr.Delay < 5*time.Minute || perr.Delay > 7*time.Minute {
+		t.Errorf("unexpected delay %s", perr.Delay)
+	}
+	if len(mock.Requests()) != requests {
+		t.Error("notification exceeding the pace should not be sent")
+	}
+}
+
+func TestPacingWait(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Pacing:  &prowl.PacingConfig{Burst: 1, Wait: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//100 calls left for the next 10 seconds: one call every 100ms
+	mock.SetRemaining(101)
+	mock.SetResetDate(time.Now().Add(10 * time.Second))
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("calls should have been paced, took %s", elapsed)
+	}
+
+	//a caller that can't wait long enough gets the error right away
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.AddContext(ctx, prowl.PrioNormal, "Event", "Description"); !errors.Is(err, prowl.ErrPaceExceeded) {
+		t.Errorf("notification should exceed the pace: %v", err)
+	}
+
+	//once the limit is reset pacing stops
+	mock.SetResetDate(time.Now().Add(-1 * time.Second))
+	<-time.After(100 * time.Millisecond)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("calls should not have been paced, took %s", elapsed)
+	}
+}
+
+func ExamplePacingConfig() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		Application: "prowlgo Example",
+		Pacing:      &prowl.PacingConfig{Burst: 5},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	_, err = client.Add(prowl.PrioNormal, "Build", "Build finished")
+	var perr *prowl.PaceError
+	if errors.As(err, &perr) {
+		fmt.Println("try again in", perr.Delay)
+	} else if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("sent")
+
+	//output:
+	//sent
+}
@@ -0,0 +1,512 @@
+// Package prowltest provides a scriptable fake prowl server for tests of code using prowlgo.
+//
+// The fake server implements the /publicapi/add, /publicapi/verify, /publicapi/retrieve/token
+// and /publicapi/retrieve/apikey requests and answers them with the same XML documents the real
+// prowl server sends. How it answers can be changed at any time, e.g. to reject api keys, to
+// run out of api calls, to respond slowly or with an internal error. All requests the server
+// receives are recorded and can be inspected afterwards.
+//
+// Point a client at the fake server using its BaseURL:
+//
+//	srv := prowltest.NewServer()
+//	defer srv.Close()
+//
+//	client, err := prowl.NewClient(prowl.Config{
+//		APIKeys: []string{"0123456789012345678901234567890123456789"},
+//		BaseURL: srv.BaseURL(),
+//	})
+package prowltest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//Token is the token handed out by the retrieve/token request.
+	Token = "c3fb7c3fb7c3fb7c3fb7c3fb7c3fb7c3fb7c3fb7"
+	//ApproveURL is the approve URL handed out by the retrieve/token request.
+	ApproveURL = "https://www.prowlapp.com/retrieve.php?token=" + Token
+	//APIKey is the api key handed out by the retrieve/apikey request.
+	APIKey = "3fa013fa013fa013fa013fa013fa013fa013fa01"
+
+	//DefaultRemaining is the number of api calls a freshly reset server grants.
+	DefaultRemaining = 992
+
+	//DefaultMaxAPIKeys is the number of api keys a freshly reset server accepts in a single
+	//add request. This is the limit of the real prowl server.
+	DefaultMaxAPIKeys = 5
+)
+
+// Request is a request received by the fake server.
+type Request struct {
+	//Time is the time the request was received.
+	Time time.Time
+	//Method is the HTTP method of the request.
+	Method string
+	//Operation is the prowl api operation ("add", "verify", "retrieve/token" or
+	//"retrieve/apikey").
+	Operation string
+	//Params holds the query and form parameters of the request.
+	Params url.Values
+	//StatusCode is the HTTP status code of the answer. It is zero if the server did not
+	//answer because it was offline or the client gave up waiting.
+	StatusCode int
+}
+
+// APIKeys returns the api keys of the request.
+func (r Request) APIKeys() []string {
+	keys := r.Params.Get("apikey")
+	if len(keys) == 0 {
+		return nil
+	}
+	return strings.Split(keys, ",")
+}
+
+// behaviour defines how the server answers requests.
+type behaviour struct {
+	acceptAPIKeys     bool
+	acceptProviderKey bool
+	tokenApproved     bool
+	approveAfter      int
+	rejectedKeys      map[string]bool
+	maxAPIKeys        int
+	remaining         int
+	resetDate         time.Time
+	delay             time.Duration
+	internalError     bool
+	failNext          int
+	malformed         bool
+	offline           bool
+}
+
+// Server is a fake prowl server. A Server is safe for concurrent use. All Set* methods
+// may be called while requests are in flight.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	behaviour behaviour
+	requests  []Request
+}
+
+// NewServer starts and returns a new fake prowl server. It accepts all keys and tokens
+// and grants DefaultRemaining api calls. The caller should call Close when finished.
+func NewServer() *Server {
+	srv := &Server{}
+	srv.Reset()
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.serveHTTP))
+	return srv
+}
+
+// BaseURL returns the URL to be used as Config.BaseURL of a client talking to this server.
+func (srv *Server) BaseURL() string {
+	return srv.URL + "/publicapi/"
+}
+
+// Transport returns a RoundTripper which redirects all requests to this server no matter
+// which host they are addressed to. Use it as Config.Transport of a client that keeps the
+// default BaseURL.
+func (srv *Server) Transport() http.RoundTripper {
+	return &rewriteTransport{srv: srv}
+}
+
+// Reset restores the default behaviour and forgets all recorded requests.
+func (srv *Server) Reset() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.behaviour = behaviour{
+		acceptAPIKeys:     true,
+		acceptProviderKey: true,
+		tokenApproved:     true,
+		rejectedKeys:      make(map[string]bool),
+		maxAPIKeys:        DefaultMaxAPIKeys,
+		remaining:         DefaultRemaining,
+		resetDate:         time.Now().Add(37 * time.Minute),
+	}
+	srv.requests = nil
+}
+
+func (srv *Server) set(f func(b *behaviour)) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	f(&srv.behaviour)
+}
+
+// SetAcceptAPIKeys defines whether api keys are accepted. If not, add and verify requests
+// are answered with error 401.
+func (srv *Server) SetAcceptAPIKeys(accept bool) {
+	srv.set(func(b *behaviour) { b.acceptAPIKeys = accept })
+}
+
+// RejectAPIKeys makes the server reject the given api keys while others are still accepted.
+// Add requests carrying at least one of these keys are answered with error 401.
+func (srv *Server) RejectAPIKeys(keys ...string) {
+	srv.set(func(b *behaviour) {
+		for _, key := range keys {
+			b.rejectedKeys[key] = true
+		}
+	})
+}
+
+// SetMaxAPIKeys sets the number of api keys accepted in a single add request. Add requests
+// with more keys are answered with error 400. Zero means no limit.
+func (srv *Server) SetMaxAPIKeys(max int) {
+	srv.set(func(b *behaviour) { b.maxAPIKeys = max })
+}
+
+// SetAcceptProviderKey defines whether provider keys are accepted. If not, retrieve requests
+// and add requests carrying a provider key are answered with error 401. Verify requests don't
+// check the provider key.
+func (srv *Server) SetAcceptProviderKey(accept bool) {
+	srv.set(func(b *behaviour) { b.acceptProviderKey = accept })
+}
+
+// SetTokenApproved defines whether the user approved the token. If not, retrieve/apikey
+// requests are answered with error 409.
+func (srv *Server) SetTokenApproved(approved bool) {
+	srv.set(func(b *behaviour) {
+		b.tokenApproved = approved
+		b.approveAfter = 0
+	})
+}
+
+// ApproveTokenAfter makes the server answer the next n retrieve/apikey requests with
+// error 409. The token is approved from then on.
+func (srv *Server) ApproveTokenAfter(n int) {
+	srv.set(func(b *behaviour) {
+		b.tokenApproved = n <= 0
+		b.approveAfter = n
+	})
+}
+
+// SetRemaining sets the number of remaining api calls. Every successful request decrements
+// the number. Once it reaches zero requests are answered with error 406.
+func (srv *Server) SetRemaining(remaining int) {
+	srv.set(func(b *behaviour) { b.remaining = remaining })
+}
+
+// SetQuotaExhausted is a shorthand for SetRemaining(0) and SetRemaining(DefaultRemaining).
+func (srv *Server) SetQuotaExhausted(exhausted bool) {
+	if exhausted {
+		srv.SetRemaining(0)
+	} else {
+		srv.SetRemaining(DefaultRemaining)
+	}
+}
+
+// SetResetDate sets the time the api call limit is reset which is reported in successful
+// answers.
+func (srv *Server) SetResetDate(reset time.Time) {
+	srv.set(func(b *behaviour) { b.resetDate = reset })
+}
+
+// SetDelay makes the server wait before it answers a request. The wait ends early if the
+// client gives up on the request.
+func (srv *Server) SetDelay(delay time.Duration) {
+	srv.set(func(b *behaviour) { b.delay = delay })
+}
+
+// SetInternalError makes the server answer all requests with error 500.
+func (srv *Server) SetInternalError(internalError bool) {
+	srv.set(func(b *behaviour) { b.internalError = internalError })
+}
+
+// FailNext makes the server answer the next n requests with error 500.
+func (srv *Server) FailNext(n int) {
+	srv.set(func(b *behaviour) { b.failNext = n })
+}
+
+// SetMalformed makes the server answer all requests with an incomplete XML document.
+func (srv *Server) SetMalformed(malformed bool) {
+	srv.set(func(b *behaviour) { b.malformed = malformed })
+}
+
+// SetOffline makes the server drop all connections without answering. Clients see this
+// as a transport error just like an unreachable server.
+func (srv *Server) SetOffline(offline bool) {
+	srv.set(func(b *behaviour) { b.offline = offline })
+}
+
+// Requests returns all requests received since the server was started or reset.
+func (srv *Server) Requests() []Request {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return append([]Request{}, srv.requests...)
+}
+
+// Adds returns all add requests that have been answered successfully since the server was
+// started or reset. These are the notifications that have been delivered.
+func (srv *Server) Adds() (adds []Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, r := range srv.requests {
+		if r.Operation == "add" && r.StatusCode == http.StatusOK {
+			adds = append(adds, r)
+		}
+	}
+	return
+}
+
+// LastAdd returns the last successful add request. ok is false if there is none.
+func (srv *Server) LastAdd() (last Request, ok bool) {
+	adds := srv.Adds()
+	if len(adds) == 0 {
+		return
+	}
+	return adds[len(adds)-1], true
+}
+
+func (srv *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	srv.mu.Lock()
+	b := srv.behaviour
+	if srv.behaviour.failNext > 0 {
+		srv.behaviour.failNext--
+		b.internalError = true
+	}
+	srv.mu.Unlock()
+
+	req := Request{
+		Time:      time.Now(),
+		Method:    r.Method,
+		Operation: strings.TrimPrefix(r.URL.Path, "/publicapi/"),
+		Params:    r.Form,
+	}
+
+	if b.offline {
+		srv.record(req)
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	select {
+	case <-time.After(b.delay):
+	case <-r.Context().Done():
+		srv.record(req)
+		return
+	}
+
+	status, body := srv.answer(r, b)
+	req.StatusCode = status
+	srv.record(req)
+
+	w.Header().Set("content-type", "text/xml")
+	w.WriteHeader(status)
+	fmt.Fprint(w, body)
+}
+
+func (srv *Server) record(req Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.requests = append(srv.requests, req)
+}
+
+// answer decides on the answer to the request. Successful requests update the state of
+// the server.
+func (srv *Server) answer(r *http.Request, b behaviour) (status int, body string) {
+	if b.internalError {
+		return http.StatusInternalServerError, internalError
+	}
+	if b.malformed {
+		return http.StatusOK, incompleteXML
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/publicapi/add":
+		keys := r.Form.Get("apikey")
+		if keys == "" {
+			return http.StatusUnauthorized, apiKeyRequired
+		}
+		if srv.behaviour.remaining <= 0 {
+			return http.StatusNotAcceptable, callLimitExceeded
+		}
+		if b.maxAPIKeys > 0 && len(strings.Split(keys, ",")) > b.maxAPIKeys {
+			return http.StatusBadRequest, tooManyAPIKeys
+		}
+		if r.Form.Get("providerkey") != "" && !b.acceptProviderKey {
+			return http.StatusUnauthorized, invalidProviderKey
+		}
+		if !b.acceptAPIKeys {
+			return http.StatusUnauthorized, invalidAPIKey
+		}
+		for _, key := range strings.Split(keys, ",") {
+			if b.rejectedKeys[key] {
+				return http.StatusUnauthorized, invalidAPIKey
+			}
+		}
+		return http.StatusOK, srv.success(add200)
+
+	case "/publicapi/verify":
+		key := r.Form.Get("apikey")
+		if key == "" {
+			return http.StatusUnauthorized, apiKeyRequired
+		}
+		if srv.behaviour.remaining <= 0 {
+			return http.StatusNotAcceptable, callLimitExceeded
+		}
+		if !b.acceptAPIKeys || b.rejectedKeys[key] {
+			return http.StatusUnauthorized, invalidAPIKey
+		}
+		return http.StatusOK, srv.success(verify200)
+
+	case "/publicapi/retrieve/token":
+		if r.Form.Get("providerkey") == "" || !b.acceptProviderKey {
+			return http.StatusUnauthorized, providerKeyRequired
+		}
+		return http.StatusOK, srv.success(retrieveToken200)
+
+	case "/publicapi/retrieve/apikey":
+		if r.Form.Get("token") == "" {
+			return http.StatusBadRequest, tokenIsRequired
+		}
+		if r.Form.Get("providerkey") == "" || !b.acceptProviderKey {
+			return http.StatusUnauthorized, providerKeyRequired
+		}
+		if srv.behaviour.approveAfter > 0 {
+			srv.behaviour.approveAfter--
+			if srv.behaviour.approveAfter == 0 {
+				srv.behaviour.tokenApproved = true
+			}
+			return http.StatusConflict, tokenNotApproved
+		}
+		if !b.tokenApproved {
+			return http.StatusConflict, tokenNotApproved
+		}
+		return http.StatusOK, srv.success(retrieveAPIKey200)
+	}
+
+	return http.StatusNotFound, notFound
+}
+
+// success renders a successful answer and counts the api call.
+// Must be called with srv.mu held.
+func (srv *Server) success(format string) string {
+	if srv.behaviour.remaining > 0 {
+		srv.behaviour.remaining--
+	}
+	return fmt.Sprintf(format, srv.behaviour.remaining, srv.behaviour.resetDate.Unix())
+}
+
+type rewriteTransport struct {
+	srv *Server
+}
+
+func (rwt *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(rwt.srv.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.URL.Path = path.Join(target.Path, req.URL.Path)
+	req.Host = ""
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+const invalidAPIKey = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<error code="401">Invalid API key</error>
+</prowl>
+`
+
+const invalidProviderKey = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<error code="401">Invalid provider key</error>
+</prowl>
+`
+
+const tooManyAPIKeys = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<error code="400">Too many API keys</error>
+</prowl>
+`
+
+const apiKeyRequired = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<error code="401">API key is required</error>
+</prowl>
+`
+
+const verify200 = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<success code="200" remaining="%d" resetdate="%d" />
+</prowl>
+`
+
+const add200 = verify200
+
+const providerKeyRequired = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<error code="401">Provider key is required.</error>
+</prowl>
+`
+
+const callLimitExceeded = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<error code="406">Not acceptable, your IP address has exceeded the API limit</error>
+</prowl>
+`
+
+const retrieveToken200 = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<success code="200" remaining="%d" resetdate="%d" />
+<retrieve token="` + Token + `" url="` + ApproveURL + `" />
+</prowl>
+`
+
+const tokenNotApproved = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<error code="409">The user has not approved your access.</error>
+</prowl>
+`
+
+const tokenIsRequired = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<error code="400">Token is required.</error>
+</prowl>
+`
+
+const retrieveAPIKey200 = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<success code="200" remaining="%d" resetdate="%d" />
+<retrieve apikey="` + APIKey + `" />
+</prowl>
+`
+
+const notFound = `<?xml version="1.0" encoding="UTF-8"?>
+<prowl>
+<error code="404">Not found.</error>
+</prowl>
+`
+
+const internalError = `<prowl>
+<error code="500">Somethign went wrong.</error>
+</prowl>
+`
+
+const incompleteXML = `<prowl>
+<error code="500">Somethign we
+`
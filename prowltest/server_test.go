@@ -0,0 +1,182 @@
+package prowltest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+	"github.com/tweithoener/prowlgo/prowltest"
+)
+
+const (
+	apiKey      = "e192384beae856efa6dda87d6a00837cf968bd8c"
+	otherAPIKey = "e19238423ae856efa6ddadf34a00837cf968bd8c"
+	providerKey = "0267157cc27a27f99ad23d1f785f0e7897df0d6b"
+)
+
+func TestServer(t *testing.T) {
+	srv := prowltest.NewServer()
+	defer srv.Close()
+
+	client, err := prowl.NewClient(prowl.Config{
+		APIKeys: []string{apiKey},
+		BaseURL: srv.BaseURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//successful requests count down the api calls
+	remaining, err := client.Add(prowl.PrioNormal, "Event", "Description")
+	if err != nil {
+		t.Error(err)
+	}
+	if remaining != prowltest.DefaultRemaining-1 {
+		t.Errorf("unexpected number of remaining calls %d", remaining)
+	}
+	last, ok := srv.LastAdd()
+	if !ok || last.Params.Get("description") != "Description" || last.APIKeys()[0] != apiKey {
+		t.Errorf("add request not recorded: %+v", last)
+	}
+
+	//the quota is spent
+	srv.SetRemaining(1)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Error(err)
+	}
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); !errors.Is(err, prowl.ErrQuotaExceeded) {
+		t.Errorf("quota should be exceeded: %v", err)
+	}
+
+	//single api keys are rejected
+	srv.Reset()
+	srv.RejectAPIKeys(otherAPIKey)
+	client, err = prowl.NewClient(prowl.Config{
+		APIKeys: []string{apiKey, otherAPIKey},
+		BaseURL: srv.BaseURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := client.Send(context.Background(), prowl.Notification{Event: "Event"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Delivered) != 1 || result.Delivered[0] != apiKey || len(result.Quarantined) != 1 || result.Quarantined[0] != otherAPIKey {
+		t.Errorf("unexpected result %+v", result)
+	}
+	adds := srv.Adds()
+	if len(adds) != 1 || adds[0].Params.Get("apikey") != apiKey {
+		t.Errorf("unexpected adds %+v", adds)
+	}
+}
+
+func TestServerFailures(t *testing.T) {
+	srv := prowltest.NewServer()
+	defer srv.Close()
+
+	client, err := prowl.NewClient(prowl.Config{
+		APIKeys:   []string{apiKey},
+		Transport: srv.Transport(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.FailNext(1)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); !prowl.DefaultRetryable(err) {
+		t.Errorf("internal error expected: %v", err)
+	}
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Error(err)
+	}
+
+	srv.SetMalformed(true)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err == nil {
+		t.Error("malformed answer should produce an error")
+	}
+
+	srv.Reset()
+	srv.SetOffline(true)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); !errors.Is(err, prowl.ErrTransport) {
+		t.Errorf("transport error expected: %v", err)
+	}
+	if reqs := srv.Requests(); len(reqs) != 1 || reqs[0].StatusCode != 0 {
+		t.Errorf("unexpected requests %+v", reqs)
+	}
+
+	srv.Reset()
+	srv.SetDelay(2 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.AddContext(ctx, prowl.PrioNormal, "Event", "Description"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deadline should be exceeded: %v", err)
+	}
+	if len(srv.Adds()) != 0 {
+		t.Error("aborted request should not be delivered")
+	}
+}
+
+func TestServerRetrieveAPIKey(t *testing.T) {
+	srv := prowltest.NewServer()
+	defer srv.Close()
+
+	client, err := prowl.NewClient(prowl.Config{
+		ProviderKey: providerKey,
+		BaseURL:     srv.BaseURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.ApproveTokenAfter(2)
+	approveURL, err := client.RetrieveToken()
+	if err != nil || approveURL != prowltest.ApproveURL {
+		t.Fatalf("unexpected approve url %s: %v", approveURL, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.RetrieveAPIKey(); !errors.Is(err, prowl.ErrTokenNotApproved) {
+			t.Errorf("token should not be approved yet: %v", err)
+		}
+	}
+	key, err := client.RetrieveAPIKey()
+	if err != nil || key != prowltest.APIKey {
+		t.Errorf("unexpected api key %s: %v", key, err)
+	}
+}
+
+func ExampleServer() {
+	srv := prowltest.NewServer()
+	defer srv.Close()
+
+	client, err := prowl.NewClient(prowl.Config{
+		APIKeys:     []string{apiKey},
+		Application: "prowltest Example",
+		BaseURL:     srv.BaseURL(),
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if _, err := client.Add(prowl.PrioHigh, "Disk full", "/dev/sda1 is full"); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	srv.SetAcceptAPIKeys(false)
+	if _, err := client.Add(prowl.PrioHigh, "Disk full", "/dev/sda2 is full"); errors.Is(err, prowl.ErrUnauthorized) {
+		fmt.Println("api key rejected")
+	}
+
+	for _, req := range srv.Adds() {
+		fmt.Println(req.Params.Get("application"), "-", req.Params.Get("description"))
+	}
+
+	//output:
+	//api key rejected
+	//prowltest Example - /dev/sda1 is full
+}
@@ -0,0 +1,461 @@
+package prowlgo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// QuietHours keep notifications of low priority from disturbing the recipients at night, on
+// weekends or whenever they don't want to be disturbed (see Config.QuietHours). During quiet
+// hours notifications with a priority up to MaxPriority are deferred until the quiet hours end
+// or dropped. Emergencies (PrioEmergency) always go through.
+//
+// Quiet hours apply to all recipients of the client or only to the listed Recipients. If
+// several quiet hours apply to a recipient at the same time, the one listed first in
+// Config.QuietHours counts. Recipients without quiet hours get the notification right away.
+// Send reports the api keys a notification was held back for in Result.Quiet.
+//
+// Deferred notifications are kept in memory. Close moves them to the outbox of the client (see
+// OutboxConfig), which delivers them when the quiet hours end, even after a restart. Without an
+// outbox Close drops them and logs how many were dropped.
+type QuietHours struct {
+	//Recipients lists the names of the recipients the quiet hours apply to. They apply to all
+	//recipients if the list is empty.
+	Recipients []string
+	//Start is the time of day the quiet hours start, e.g. "22:00" or "22:00:00".
+	Start string
+	//End is the time of day the quiet hours end, e.g. "07:00". Quiet hours ending before they
+	//start end on the next day, quiet hours ending at their start last 24 hours.
+	End string
+	//Days lists the weekdays the quiet hours start on. Defaults to every day.
+	Days []time.Weekday
+	//TimeZone is the IANA name of the time zone of Start and End, e.g. "Europe/Berlin".
+	//Defaults to the local time zone.
+	TimeZone string
+	//MaxPriority is the highest priority of the notifications held back. It must not be
+	//PrioEmergency. Note that the zero value is PrioNormal.
+	MaxPriority int
+	//Drop drops the notifications instead of deferring them until the quiet hours end.
+	Drop bool
+}
+
+// quietRule is validated QuietHours.
+type quietRule struct {
+	QuietHours
+	//start and end are the seconds since midnight.
+	start, end int
+	loc        *time.Location
+}
+
+// addQuietHours validates and stores quiet hours. Must be called with clt.mu held.
+func (clt *Client) addQuietHours(qh QuietHours) error {
+	for _, name := range qh.Recipients {
+		if _, ok := clt.recipients[name]; !ok {
+			return newFieldError("QuietHours", "%s is not a recipient", name)
+		}
+	}
+	start, err := parseTimeOfDay(qh.Start)
+	if err != nil {
+		return newFieldError("QuietHours", "invalid start %q of quiet hours", qh.Start)
+	}
+	end, err := parseTimeOfDay(qh.End)
+	if err != nil {
+		return newFieldError("QuietHours", "invalid end %q of quiet hours", qh.End)
+	}
+	for _, day := range qh.Days {
+		if day < time.Sunday || day > time.Saturday {
+			return newFieldError("QuietHours", "invalid weekday %d of quiet hours", day)
+		}
+	}
+	loc := time.Local
+	if len(qh.TimeZone) > 0 {
+		if loc, err = time.LoadLocation(qh.TimeZone); err != nil {
+			return newFieldError("QuietHours", "unknown time zone %s of quiet hours", qh.TimeZone)
+		}
+	}
+	if qh.MaxPriority < PrioVeryLow || qh.MaxPriority > PrioHigh {
+		return newFieldError("QuietHours", "max priority of quiet hours must be in the range -2..1")
+	}
+
+	qh.Recipients = append([]string(nil), qh.Recipients...)
+	qh.Days = append([]time.Weekday(nil), qh.Days...)
+	clt.quietHours = append(clt.quietHours, &quietRule{QuietHours: qh, start: start, end: end, loc: loc})
+	return nil
+}
+
+// parseTimeOfDay returns the seconds since midnight of a time like "22:00" or "22:00:00".
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04:05", s)
+	if err != nil {
+		if t, err = time.Parse("15:04", s); err != nil {
+			return 0, err
+		}
+	}
+	return t.Hour()*3600 + t.Minute()*60 + t.Second(), nil
+}
+
+// forgetQuietRecipient removes a recipient from all quiet hours. Quiet hours left without
+// recipients are removed. Must be called with clt.mu held.
+func (clt *Client) forgetQuietRecipient(recipient string) {
+	rules := clt.quietHours[:0]
+	for _, r := range clt.quietHours {
+		if len(r.Recipients) > 0 {
+			names := r.Recipients[:0]
+			for _, name := range r.Recipients {
+				if name != recipient {
+					names = append(names, name)
+				}
+			}
+			if len(names) == 0 {
+				continue
+			}
+			r.Recipients = names
+		}
+		rules = append(rules, r)
+	}
+	clt.quietHours = rules
+}
+
+// quietHoursConfig returns the quiet hours in the format of Config.QuietHours. Must be called
+// with clt.mu held.
+func (clt *Client) quietHoursConfig() (ret []QuietHours) {
+	for _, r := range clt.quietHours {
+		cpy := r.QuietHours
+		cpy.Recipients = append([]string(nil), r.Recipients...)
+		cpy.Days = append([]time.Weekday(nil), r.Days...)
+		ret = append(ret, cpy)
+	}
+	return
+}
+
+// QuietUntil returns the end of the quiet hours of the recipient at the given time for
+// notifications of the given priority (see QuietHours). It returns the zero time if the
+// recipient is not in its quiet hours.
+func (clt *Client) QuietUntil(recipient string, priority int, at time.Time) (time.Time, error) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	if _, ok := clt.recipients[recipient]; !ok {
+		return time.Time{}, newFieldError("recipient", "unknown recipient %s", recipient)
+	}
+	for _, r := range clt.quietHours {
+		if !r.holds(priority) || !r.appliesTo(recipient) {
+			continue
+		}
+		if until, ok := r.until(at); ok {
+			return until, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// holds reports whether notifications of the given priority are held back.
+func (r *quietRule) holds(priority int) bool {
+	return priority <= r.MaxPriority
+}
+
+func (r *quietRule) appliesTo(recipient string) bool {
+	if len(r.Recipients) == 0 {
+		return true
+	}
+	for _, name := range r.Recipients {
+		if name == recipient {
+			return true
+		}
+	}
+	return false
+}
+
+// until returns the end of the quiet hours if they are in effect at the given time.
+func (r *quietRule) until(at time.Time) (time.Time, bool) {
+	t := at.In(r.loc)
+	//quiet hours that started yesterday might not be over yet
+	for _, offset := range []int{-1, 0} {
+		day := t.Day() + offset
+		begin := r.clock(t, day, r.start)
+		if !r.on(begin.Weekday()) {
+			continue
+		}
+		if r.end <= r.start {
+			day++
+		}
+		end := r.clock(t, day, r.end)
+		if !t.Before(begin) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// clock returns the time on the given day of the month of t, seconds after midnight.
+func (r *quietRule) clock(t time.Time, day int, seconds int) time.Time {
+	return time.Date(t.Year(), t.Month(), day, seconds/3600, seconds/60%60, seconds%60, 0, r.loc)
+}
+
+// on reports whether the quiet hours start on the given weekday.
+func (r *quietRule) on(weekday time.Weekday) bool {
+	if len(r.Days) == 0 {
+		return true
+	}
+	for _, day := range r.Days {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// quietKeys are the api keys a notification is held back for by the same quiet hours.
+type quietKeys struct {
+	keys  []string
+	until time.Time
+	drop  bool
+}
+
+// splitQuiet splits the target api keys of the notification into the keys it can be sent to
+// and the keys of recipients in their quiet hours. The api keys of the notification are
+// returned unchanged if no recipient is in its quiet hours.
+func (clt *Client) splitQuiet(n notification, now time.Time) (loud []string, quiet []quietKeys) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	type activeRule struct {
+		quietKeys
+		//keys of the recipients of the rule, nil if it applies to all recipients
+		targets map[string]bool
+	}
+	var active []*activeRule
+	for _, r := range clt.quietHours {
+		if !r.holds(n.Priority) {
+			continue
+		}
+		until, ok := r.until(now)
+		if !ok {
+			continue
+		}
+		a := &activeRule{quietKeys: quietKeys{until: until, drop: r.Drop}}
+		if len(r.Recipients) > 0 {
+			a.targets = make(map[string]bool, len(r.Recipients))
+			for _, name := range r.Recipients {
+				a.targets[clt.recipients[name]] = true
+			}
+		}
+		active = append(active, a)
+	}
+	if len(active) == 0 {
+		return n.APIKeys, nil
+	}
+
+	targets := n.APIKeys
+	if len(targets) == 0 {
+		targets = clt.activeAPIKeys()
+	}
+	for _, key := range targets {
+		held := false
+		for _, a := range active {
+			if a.targets == nil || a.targets[key] {
+				a.keys = append(a.keys, key)
+				held = true
+				break
+			}
+		}
+		if !held {
+			loud = append(loud, key)
+		}
+	}
+	for _, a := range active {
+		if len(a.keys) > 0 {
+			quiet = append(quiet, a.quietKeys)
+		}
+	}
+	if len(quiet) == 0 {
+		return n.APIKeys, nil
+	}
+	return loud, quiet
+}
+
+// dispatchQuietly defers or drops the notification for recipients in their quiet hours and
+// forwards it to all others.
+func (clt *Client) dispatchQuietly(ctx context.Context, n notification) (result Result, err error) {
+	loud, quiet := clt.splitQuiet(n, time.Now())
+	if len(quiet) == 0 {
+		return clt.forward(ctx, n)
+	}
+
+	var held []string
+	deferred := false
+	for _, q := range quiet {
+		held = append(held, q.keys...)
+		if q.drop {
+			continue
+		}
+		dn := n
+		dn.APIKeys = q.keys
+		clt.deferrals.add(q.until, dn)
+		deferred = true
+	}
+	sort.Strings(held)
+
+	if len(loud) == 0 {
+		result.Remaining = clt.remainingCalls()
+		result.Status = StatusDropped
+		if deferred {
+			result.Status = StatusDeferred
+		}
+		result.Quiet = held
+		return result, nil
+	}
+	n.APIKeys = loud
+	result, err = clt.forward(ctx, n)
+	result.Quiet = held
+	return result, err
+}
+
+// deferral is a notification deferred until the end of quiet hours.
+type deferral struct {
+	due time.Time
+	n   notification
+}
+
+// deferrals holds the notifications deferred by quiet hours until they are due.
+type deferrals struct {
+	clt *Client
+
+	mu    sync.Mutex
+	items []deferral
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newDeferrals(clt *Client) *deferrals {
+	df := &deferrals{
+		clt:  clt,
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go df.run()
+	return df
+}
+
+func (df *deferrals) add(due time.Time, n notification) {
+	df.mu.Lock()
+	df.items = append(df.items, deferral{due: due, n: n})
+	df.mu.Unlock()
+
+	select {
+	case df.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the time the next deferred notification is due.
+func (df *deferrals) next() (next time.Time, ok bool) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	for _, d := range df.items {
+		if !ok || d.due.Before(next) {
+			next, ok = d.due, true
+		}
+	}
+	return
+}
+
+// take removes and returns the notifications due at the given time.
+func (df *deferrals) take(now time.Time) (due []notification) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	items := df.items[:0]
+	for _, d := range df.items {
+		if d.due.After(now) {
+			items = append(items, d)
+			continue
+		}
+		due = append(due, d.n)
+	}
+	df.items = items
+	return
+}
+
+func (df *deferrals) run() {
+	defer close(df.done)
+
+	for {
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if next, ok := df.next(); ok {
+			timer = time.NewTimer(time.Until(next))
+			timeout = timer.C
+		}
+		select {
+		case <-df.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-df.wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		for _, n := range df.take(time.Now()) {
+			//quiet hours might follow each other, so the notification is checked again
+			ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+			result, err := df.clt.dispatch(ctx, n)
+			if err != nil {
+				df.clt.config.Logger.Printf("deferred prowl message (\"%s\") can't be sent: %s", n.Event, err)
+			}
+			if len(n.incident) > 0 {
+				df.clt.escalator.settle(n.incident, result, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// close stops the deferrals. The notifications deferred so far are moved to the outbox, which
+// delivers them when they are due. They are dropped if the client has no outbox.
+func (df *deferrals) close() error {
+	select {
+	case <-df.stop:
+		return nil
+	default:
+	}
+	close(df.stop)
+	<-df.done
+
+	df.mu.Lock()
+	items := df.items
+	df.items = nil
+	df.mu.Unlock()
+
+	if len(items) == 0 {
+		return nil
+	}
+	if df.clt.outbox == nil {
+		df.clt.config.Logger.Printf("%d deferred prowl messages dropped: client has no outbox", len(items))
+		return nil
+	}
+	var errs []error
+	for _, d := range items {
+		if err := df.clt.outbox.hold(d.n, d.due); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("can't move deferred notification to the outbox: %w", errs[0])
+	}
+	return fmt.Errorf("%d deferred notifications can't be moved to the outbox, first error: %w", len(errs), errs[0])
+}
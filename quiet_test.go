@@ -0,0 +1,253 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestQuietHoursConfig(t *testing.T) {
+	recipients := map[string]string{"alice": multipleValidAPIKeys[0]}
+	for _, qh := range []prowl.QuietHours{
+		{Start: "22:00"},
+		{Start: "22:00", End: "7 am"},
+		{Start: "22:00", End: "07:00", Recipients: []string{"bob"}},
+		{Start: "22:00", End: "07:00", Days: []time.Weekday{7}},
+		{Start: "22:00", End: "07:00", TimeZone: "Europe/Nowhere"},
+		{Start: "22:00", End: "07:00", MaxPriority: prowl.PrioEmergency},
+	} {
+		if _, err := prowl.NewClient(prowl.Config{Recipients: recipients, QuietHours: []prowl.QuietHours{qh}}); !errors.Is(err, prowl.ErrInvalidArgument) {
+			t.Errorf("invalid quiet hours %+v should produce an error: %v", qh, err)
+		}
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		Recipients: map[string]string{"alice": multipleValidAPIKeys[0], "bob": multipleValidAPIKeys[1]},
+		QuietHours: []prowl.QuietHours{
+			{Start: "23:00", End: "06:00"},
+			{Recipients: []string{"alice", "bob"}, Start: "12:00", End: "13:00"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.RemoveRecipient("alice")
+	client.RemoveRecipient("bob")
+	if got := client.Config().QuietHours; len(got) != 1 || got[0].Start != "23:00" || len(got[0].Recipients) != 0 {
+		t.Errorf("quiet hours without recipients should be removed, got %+v", got)
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	client, err := prowl.NewClient(prowl.Config{
+		Recipients: map[string]string{"alice": multipleValidAPIKeys[0], "bob": multipleValidAPIKeys[1]},
+		QuietHours: []prowl.QuietHours{
+			{Recipients: []string{"alice"}, Days: weekdays, Start: "22:00", End: "07:00", TimeZone: "Europe/Berlin"},
+			{Recipients: []string{"alice"}, Days: []time.Weekday{time.Saturday, time.Sunday}, Start: "00:00", End: "00:00", TimeZone: "Europe/Berlin", MaxPriority: prowl.PrioHigh},
+			{Recipients: []string{"bob"}, Start: "01:00", End: "04:00", TimeZone: "Europe/Berlin"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, berlin)
+	}
+	for _, c := range []struct {
+		recipient string
+		priority  int
+		at        time.Time
+		want      time.Time
+	}{
+		{"alice", prowl.PrioNormal, at(3, 4, 12, 0), time.Time{}},
+		{"alice", prowl.PrioNormal, at(3, 4, 23, 0), at(3, 5, 7, 0)},
+		{"alice", prowl.PrioNormal, at(3, 5, 6, 59), at(3, 5, 7, 0)},
+		{"alice", prowl.PrioNormal, at(3, 5, 7, 0), time.Time{}},
+		{"alice", prowl.PrioHigh, at(3, 4, 23, 0), time.Time{}},
+		{"alice", prowl.PrioEmergency, at(3, 9, 12, 0), time.Time{}},
+		//friday night ends saturday morning, the first quiet hours count
+		{"alice", prowl.PrioNormal, at(3, 9, 1, 0), at(3, 9, 7, 0)},
+		{"alice", prowl.PrioHigh, at(3, 9, 1, 0), at(3, 10, 0, 0)},
+		{"alice", prowl.PrioNormal, at(3, 10, 12, 0), at(3, 11, 0, 0)},
+		{"alice", prowl.PrioNormal, at(3, 11, 1, 0), time.Time{}},
+		//start of daylight saving time
+		{"bob", prowl.PrioNormal, time.Date(2024, 3, 31, 0, 30, 0, 0, time.UTC), time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC)},
+		{"bob", prowl.PrioNormal, time.Date(2024, 4, 1, 0, 30, 0, 0, time.UTC), time.Date(2024, 4, 1, 2, 0, 0, 0, time.UTC)},
+		{"bob", prowl.PrioNormal, time.Date(2024, 4, 1, 2, 30, 0, 0, time.UTC), time.Time{}},
+	} {
+		got, err := client.QuietUntil(c.recipient, c.priority, c.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(c.want) {
+			t.Errorf("%s (priority %d) at %s: got %s, want %s", c.recipient, c.priority, c.at, got, c.want)
+		}
+	}
+
+	if _, err := client.QuietUntil("carol", prowl.PrioNormal, time.Now()); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown recipient should produce an error: %v", err)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	alice, bob, carol := multipleValidAPIKeys[0], multipleValidAPIKeys[1], multipleValidAPIKeys[2]
+	now := time.Now().UTC()
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:    mock.BaseURL(),
+		Recipients: map[string]string{"alice": alice, "bob": bob, "carol": carol},
+		QuietHours: []prowl.QuietHours{
+			{Recipients: []string{"alice"}, Start: now.Add(-time.Hour).Format("15:04:05"), End: now.Add(2 * time.Second).Format("15:04:05"), TimeZone: "UTC"},
+			{Recipients: []string{"carol"}, Start: now.Add(-time.Hour).Format("15:04"), End: now.Add(time.Hour).Format("15:04"), TimeZone: "UTC", Drop: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	//bob gets it right away, alice later, carol never
+	if _, err := client.Add(prowl.PrioNormal, "Backup", "finished"); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); len(mock.Adds()) != 1 || !reflect.DeepEqual(last.APIKeys(), []string{bob}) {
+		t.Errorf("notification should be sent to bob only, got %v", mock.Adds())
+	}
+	result, err := client.Send(context.Background(), prowl.Notification{To: []string{"alice", "carol"}, Event: "Backup"})
+	quiet := []string{alice, carol}
+	sort.Strings(quiet)
+	if err != nil || result.Status != prowl.StatusDeferred || !reflect.DeepEqual(result.Quiet, quiet) {
+		t.Errorf("notification should be deferred %+v: %v", result, err)
+	}
+	if _, err := client.AddTo([]string{"carol"}, prowl.PrioVeryLow, "Backup", ""); err != nil {
+		t.Error(err)
+	}
+	if n := len(mock.Adds()); n != 1 {
+		t.Errorf("notifications should be held back, got %d", n)
+	}
+
+	//emergencies always go through
+	if _, err := client.AddTo([]string{"carol"}, prowl.PrioEmergency, "Fire", ""); err != nil {
+		t.Error(err)
+	}
+	if n := len(mock.Adds()); n != 2 {
+		t.Errorf("emergency should be sent, got %d notifications", n)
+	}
+
+	waitFor(t, func() bool { return len(mock.Adds()) == 4 })
+	for _, add := range mock.Adds()[2:] {
+		if !reflect.DeepEqual(add.APIKeys(), []string{alice}) || add.Params.Get("event") != "Backup" {
+			t.Errorf("deferred notification should be sent to alice, got %v", add.Params)
+		}
+	}
+}
+
+func TestQuietHoursClose(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	//without an outbox deferred notifications are dropped
+	logbuf := &syncBuffer{}
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:    mock.BaseURL(),
+		APIKeys:    aValidAPIKey,
+		QuietHours: []prowl.QuietHours{{Start: "00:00", End: "00:00"}},
+		Logger:     log.New(logbuf, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mock.Adds()); n != 0 || !strings.Contains(logbuf.String(), "1 deferred prowl messages dropped") {
+		t.Errorf("deferred notification should be dropped, got %d notifications: %s", n, logbuf.String())
+	}
+
+	//with an outbox they survive a restart and are still not sent during the quiet hours
+	config := prowl.Config{
+		BaseURL:    mock.BaseURL(),
+		APIKeys:    aValidAPIKey,
+		QuietHours: []prowl.QuietHours{{Start: "00:00", End: "00:00"}},
+		Outbox:     &prowl.OutboxConfig{Path: filepath.Join(t.TempDir(), "outbox"), RetryInterval: 50 * time.Millisecond},
+	}
+	client, err = prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	config.QuietHours = nil
+	client, err = prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	<-time.After(200 * time.Millisecond)
+	if n := len(mock.Adds()); n != 0 || client.OutboxStats().Pending != 1 {
+		t.Errorf("deferred notification should wait in the outbox, got %d notifications", n)
+	}
+	//and they don't hold back other notifications
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Now"); err != nil {
+		t.Error(err)
+	}
+	if n := len(mock.Adds()); n != 1 {
+		t.Errorf("notification should be sent right away, got %d notifications", n)
+	}
+}
+
+func ExampleQuietHours() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		Application: "prowlgo Example",
+		Recipients:  map[string]string{"alice": aValidAPIKey[0]},
+		QuietHours: []prowl.QuietHours{{
+			Recipients: []string{"alice"},
+			Days:       []time.Weekday{time.Saturday, time.Sunday},
+			Start:      "00:00",
+			End:        "00:00",
+			TimeZone:   "Europe/Berlin",
+			Drop:       true,
+		}},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	//Clients with quiet hours must be closed.
+	defer client.Close()
+
+	until, err := client.QuietUntil("alice", prowl.PrioNormal, time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("alice is not disturbed until", until.UTC())
+
+	//output:
+	//alice is not disturbed until 2024-03-09 23:00:00 +0000 UTC
+}
@@ -0,0 +1,146 @@
+package prowlgo
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// AddTo is the same as Add() but the notification is only sent to the named recipients,
+// groups and rotations (see Config.Recipients, Config.Groups and Config.Rotations). Unknown
+// recipients produce an error matching ErrInvalidArgument and nothing is sent.
+func (clt *Client) AddTo(recipients []string, priority int, event string, description string) (remaining int, err error) {
+	return clt.AddToContext(context.Background(), recipients, priority, event, description)
+}
+
+// AddToContext is the same as AddTo() but the request to the prowl server is bound to
+// the provided context.
+func (clt *Client) AddToContext(ctx context.Context, recipients []string, priority int, event string, description string) (remaining int, err error) {
+	if len(recipients) == 0 {
+		return clt.remainingCalls(), newFieldError("recipients", "at least one recipient is required")
+	}
+	result, err := clt.Send(ctx, Notification{
+		To:          recipients,
+		Priority:    priority,
+		Event:       event,
+		Description: description,
+	})
+	return result.Remaining, err
+}
+
+// AddRecipient adds a named recipient to this client or changes the api key of an existing
+// one. The key is added to the keys of the client (see AddAPIKey). If the api key of a
+// recipient changes, its old key is removed from the client unless another recipient uses it.
+// The name must not be the name of a group or a rotation.
+func (clt *Client) AddRecipient(name string, apiKey string) error {
+	if err := validateRecipient(name, apiKey); err != nil {
+		return err
+	}
+
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	if clt.groups.has(name) || clt.rotations[name] != nil {
+		return newFieldError("Recipients", "recipient %s must not have the name of a group or a rotation", name)
+	}
+
+	old, ok := clt.recipients[name]
+	clt.recipients[name] = apiKey
+	clt.apiKeys[apiKey] = true
+	clt.apiKeysDirty = true
+	if ok && old != apiKey && !clt.recipientKey(old) {
+		delete(clt.apiKeys, old)
+	}
+	return nil
+}
+
+// recipientKey reports whether a recipient uses the api key. Must be called with clt.mu held.
+func (clt *Client) recipientKey(apiKey string) bool {
+	for _, key := range clt.recipients {
+		if key == apiKey {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveRecipient removes the named recipient from the client, its groups, rotations and
+// topics.
+// Its api key remains with the client. Use RemoveAPIKey to stop sending notifications to
+// the recipient altogether. Unknown names are handled silently.
+func (clt *Client) RemoveRecipient(name string) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	if _, ok := clt.recipients[name]; !ok {
+		return
+	}
+	delete(clt.recipients, name)
+	clt.forgetRecipient(name)
+}
+
+// Recipients returns the names of all recipients of this client in alphabetical order.
+func (clt *Client) Recipients() (names []string) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	for name := range clt.recipients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func validateRecipient(name string, apiKey string) error {
+	if len(name) == 0 {
+		return newFieldError("Recipients", "recipient name must not be empty")
+	}
+	if len(apiKey) != 40 {
+		return newFieldError("Recipients", "api key of recipient %s must be exactly 40 chars long", name)
+	}
+	return nil
+}
+
+// resolveRecipients returns the api keys of the named recipients, groups and rotations and
+// of the subscribers of topic. Rotations resolve to the recipient currently on call.
+func (clt *Client) resolveRecipients(names []string, topic string) (apiKeys []string, err error) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var resolve func(name string, field string) error
+	resolve = func(name string, field string) error {
+		if key, ok := clt.recipients[name]; ok {
+			if !seen[key] {
+				seen[key] = true
+				apiKeys = append(apiKeys, key)
+			}
+			return nil
+		}
+		if r := clt.rotations[name]; r != nil {
+			return resolve(r.onCall(time.Now()), field)
+		}
+		if !clt.groups.has(name) {
+			return newFieldError(field, "unknown recipient %s", name)
+		}
+		for _, member := range clt.groups.members(name) {
+			if err := resolve(member, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		if err = resolve(name, "recipients"); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range clt.topics.members(topic) {
+		if err = resolve(name, "topic"); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(apiKeys)
+	return
+}
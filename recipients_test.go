@@ -0,0 +1,147 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestAddTo(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	alice, bob, ops := multipleValidAPIKeys[0], multipleValidAPIKeys[1], multipleValidAPIKeys[2]
+
+	if _, err := prowl.NewClient(prowl.Config{Recipients: map[string]string{"alice": "short"}}); err == nil {
+		t.Error("invalid recipient key should produce an error")
+	}
+	if _, err := prowl.NewClient(prowl.Config{Recipients: map[string]string{"": alice}}); err == nil {
+		t.Error("empty recipient name should produce an error")
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:    mock.BaseURL(),
+		APIKeys:    []string{ops},
+		Recipients: map[string]string{"alice": alice, "bob": bob},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(client.Recipients(), []string{"alice", "bob"}) {
+		t.Errorf("unexpected recipients %v", client.Recipients())
+	}
+
+	//only the named recipients get the notification
+	if _, err := client.AddTo([]string{"bob", "alice", "bob"}, prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); !sameKeys(last.APIKeys(), alice, bob) {
+		t.Errorf("unexpected api keys %v", last.APIKeys())
+	}
+
+	//broadcasts still go to everybody
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); len(last.APIKeys()) != 3 {
+		t.Errorf("unexpected api keys %v", last.APIKeys())
+	}
+
+	//unknown recipients are rejected and nothing is sent
+	requests := len(mock.Requests())
+	if _, err := client.AddTo([]string{"alice", "carol"}, prowl.PrioNormal, "Event", "Description"); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown recipient should produce an error: %v", err)
+	}
+	if _, err := client.AddTo(nil, prowl.PrioNormal, "Event", "Description"); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("missing recipients should produce an error: %v", err)
+	}
+	if len(mock.Requests()) != requests {
+		t.Error("nothing should be sent to unknown recipients")
+	}
+
+	//recipients can be changed at runtime
+	if err := client.AddRecipient("carol", ops); err != nil {
+		t.Fatal(err)
+	}
+	result, err := client.Send(context.Background(), prowl.Notification{To: []string{"carol"}, Event: "Event"})
+	if err != nil || !reflect.DeepEqual(result.Delivered, []string{ops}) {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+	client.RemoveRecipient("carol")
+	if _, err := client.AddTo([]string{"carol"}, prowl.PrioNormal, "Event", "Description"); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("removed recipient should produce an error: %v", err)
+	}
+	if err := client.RemoveAPIKey(bob); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(client.Recipients(), []string{"alice"}) {
+		t.Errorf("recipient of removed key should be gone: %v", client.Recipients())
+	}
+
+	//the old key of a recipient no longer gets broadcasts
+	phone := "0000000000000000000000000000000000000001"
+	if err := client.AddRecipient("alice", phone); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); !sameKeys(last.APIKeys(), ops, phone) {
+		t.Errorf("unexpected api keys %v", last.APIKeys())
+	}
+	if err := client.AddRecipient("alice", alice); err != nil {
+		t.Fatal(err)
+	}
+
+	//a rejected recipient is quarantined like any other key
+	mock.RejectAPIKeys(alice)
+	if _, err := client.AddTo([]string{"alice"}, prowl.PrioNormal, "Event", "Description"); !errors.Is(err, prowl.ErrUnauthorized) {
+		t.Errorf("rejected recipient should produce an error: %v", err)
+	}
+	if !reflect.DeepEqual(client.QuarantinedAPIKeys(), []string{alice}) {
+		t.Errorf("unexpected quarantined keys %v", client.QuarantinedAPIKeys())
+	}
+
+	//recipients survive the round trip through the config
+	config := client.Config()
+	if !reflect.DeepEqual(config.Recipients, map[string]string{"alice": alice}) {
+		t.Errorf("unexpected recipients in config %v", config.Recipients)
+	}
+}
+
+// sameKeys reports whether got holds exactly the wanted keys in any order.
+func sameKeys(got []string, want ...string) bool {
+	got = append([]string(nil), got...)
+	want = append([]string(nil), want...)
+	sort.Strings(got)
+	sort.Strings(want)
+	return reflect.DeepEqual(got, want)
+}
+
+func ExampleClient_AddTo() {
+	client, err := prowl.NewBuilder().
+		SetBaseURL(mock.BaseURL()).
+		SetApplication("prowlgo Example").
+		AddRecipient("alice", "e192384beae856efa6dda87d6a00837cf968bd8c").
+		AddRecipient("ops-phone", "e19238423ae856efa6ddadf34a00837cf968bd8c").
+		Build()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	//Only the ops phone is woken up
+	if _, err := client.AddTo([]string{"ops-phone"}, prowl.PrioEmergency, "Database down", "db1 is not responding"); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("recipients:", client.Recipients())
+
+	//output:
+	//recipients: [alice ops-phone]
+}
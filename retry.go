@@ -0,0 +1,120 @@
+package prowlgo
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = 1 * time.Second
+	defaultRetryMaxDelay  = 1 * time.Minute
+)
+
+// RetryPolicy defines if and how requests to the prowl server are repeated when they fail.
+// The delay between two attempts starts at BaseDelay and is doubled for every further
+// attempt until it reaches MaxDelay. Set it as Config.Retry to enable retries. Without a
+// policy every request is sent exactly once.
+type RetryPolicy struct {
+	//MaxAttempts is the maximum number of attempts including the first one.
+	//Values below 2 disable retries.
+	MaxAttempts int
+
+	//BaseDelay is the delay before the first retry. Defaults to one second.
+	BaseDelay time.Duration
+
+	//MaxDelay limits the delay between two attempts. Defaults to one minute.
+	MaxDelay time.Duration
+
+	//Jitter is the fraction (0..1) of each delay that is randomized. A jitter of 0.2
+	//shortens each delay randomly by up to 20 percent. This avoids many clients hitting
+	//the prowl server at the very same time after an outage.
+	Jitter float64
+
+	//Retryable decides if a failed attempt is to be repeated. Defaults to DefaultRetryable.
+	Retryable func(err error) bool `json:"-"`
+}
+
+// DefaultRetryable reports whether err is worth another attempt. This is the case for
+// transport errors (the prowl server could not be reached) and server side errors (5xx).
+// Rejected keys (401), bad requests (400), an exceeded api limit (406) and the like will
+// not change by trying again. Neither will a canceled request.
+func DefaultRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrTransport) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code >= 500 || apiErr.StatusCode >= 500
+	}
+	return false
+}
+
+func (rp *RetryPolicy) validate() error {
+	if rp.MaxAttempts < 0 {
+		return newFieldError("Retry", "retry max attempts must not be negative")
+	}
+	if rp.BaseDelay < 0 || rp.MaxDelay < 0 {
+		return newFieldError("Retry", "retry delays must not be negative")
+	}
+	if rp.Jitter < 0 || rp.Jitter > 1 {
+		return newFieldError("Retry", "retry jitter must be in the range 0..1")
+	}
+	return nil
+}
+
+func (rp *RetryPolicy) retryable(err error) bool {
+	if rp.Retryable != nil {
+		return rp.Retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// delay returns the time to wait after the given (failed) attempt.
+func (rp *RetryPolicy) delay(attempt int) time.Duration {
+	base, max := rp.BaseDelay, rp.MaxDelay
+	if base == 0 {
+		base = defaultRetryBaseDelay
+	}
+	if max == 0 {
+		max = defaultRetryMaxDelay
+	}
+
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if rp.Jitter > 0 {
+		d -= time.Duration(rp.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// request sends a request using send and handles the response. Failed attempts are
+// repeated according to the retry policy of the client until ctx is done.
+func (clt *Client) request(ctx context.Context, operation string, send func() (*http.Response, error)) (response Response, err error) {
+	policy := clt.config.Retry
+	for attempt := 1; ; attempt++ {
+		resp, rerr := send()
+		response, err = clt.handleResponse(operation, resp, rerr)
+		if err == nil || policy == nil || attempt >= policy.MaxAttempts || ctx.Err() != nil || !policy.retryable(err) {
+			return
+		}
+
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestRetry(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	if _, err := prowl.NewClient(prowl.Config{Retry: &prowl.RetryPolicy{MaxAttempts: -1}}); err == nil {
+		t.Error("negative max attempts should produce an error")
+	}
+	if _, err := prowl.NewClient(prowl.Config{Retry: &prowl.RetryPolicy{BaseDelay: -1}}); err == nil {
+		t.Error("negative delay should produce an error")
+	}
+	if _, err := prowl.NewClient(prowl.Config{Retry: &prowl.RetryPolicy{Jitter: 1.5}}); err == nil {
+		t.Error("jitter out of range should produce an error")
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Retry: &prowl.RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   10 * time.Millisecond,
+			Jitter:      0.5,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//two internal errors in a row are fine with three attempts
+	mock.FailNext(2)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Error(err)
+	}
+	if len(mock.Requests()) != 3 {
+		t.Errorf("expected 3 requests but server got %d", len(mock.Requests()))
+	}
+
+	//three are too many
+	mock.Reset()
+	mock.FailNext(3)
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err == nil {
+		t.Error("internal error on all attempts should produce an error")
+	}
+	if len(mock.Requests()) != 3 {
+		t.Errorf("expected 3 requests but server got %d", len(mock.Requests()))
+	}
+
+	//client errors are not retried
+	for _, c := range []struct {
+		set      func()
+		requests int
+	}{
+		//the rejected add is followed by a verify of the api key to tell a rejected
+		//provider key apart
+		{func() { mock.SetAcceptProviderKey(false) }, 3},
+		{func() { mock.SetTokenApproved(false) }, 2},
+		{func() { mock.SetQuotaExhausted(true) }, 2},
+	} {
+		mock.Reset()
+		c.set()
+		client, err := prowl.NewClient(prowl.Config{
+			BaseURL:     mock.BaseURL(),
+			APIKeys:     aValidAPIKey,
+			ProviderKey: aValidProviderKey,
+			Token:       "0987609876098760987609876098760987609876",
+			Retry:       &prowl.RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.Add(prowl.PrioNormal, "Event", "Description")
+		client.RetrieveAPIKey()
+		if len(mock.Requests()) != c.requests {
+			t.Errorf("expected %d requests but server got %d", c.requests, len(mock.Requests()))
+		}
+	}
+
+	//transport errors are retried
+	mock.Reset()
+	mock.SetOffline(true)
+	calls := 0
+	client, err = prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Retry: &prowl.RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   10 * time.Millisecond,
+			Retryable: func(err error) bool {
+				calls++
+				if !errors.Is(err, prowl.ErrTransport) {
+					t.Error("transport error expected")
+				}
+				return prowl.DefaultRetryable(err)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Add(prowl.PrioNormal, "Event", "Description"); err == nil {
+		t.Error("server not responding should produce an error")
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 retry decisions but got %d", calls)
+	}
+
+	//the caller's context limits the retries
+	mock.Reset()
+	mock.FailNext(3)
+	client, err = prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+		Retry:   &prowl.RetryPolicy{MaxAttempts: 3, BaseDelay: 1 * time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	before := time.Now()
+	if _, err := client.AddContext(ctx, prowl.PrioNormal, "Event", "Description"); err == nil {
+		t.Error("internal error should produce an error")
+	}
+	if time.Since(before) > 500*time.Millisecond || len(mock.Requests()) != 1 {
+		t.Error("retry did not honour the context")
+	}
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{&prowl.APIError{Code: 500, StatusCode: 500}, true},
+		{&prowl.APIError{Code: 503, StatusCode: 503}, true},
+		{&prowl.APIError{Code: 401, StatusCode: 401}, false},
+		{&prowl.APIError{Code: 400, StatusCode: 400}, false},
+		{&prowl.APIError{Code: 406, StatusCode: 406}, false},
+		{&prowl.APIError{Code: 409, StatusCode: 409}, false},
+		{fmt.Errorf("add request to prowl server failed: %w", &prowl.APIError{Code: 500}), true},
+		{&prowl.FieldError{Field: "event"}, false},
+		{errors.New("something"), false},
+	}
+	for _, c := range cases {
+		if prowl.DefaultRetryable(c.err) != c.retryable {
+			t.Errorf("DefaultRetryable(%v) should be %t", c.err, c.retryable)
+		}
+	}
+}
+
+func ExampleRetryPolicy() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		Application: "prowlgo Example",
+		//Try up to five times. Wait 1s, 2s, 4s and 8s (minus up to 20% jitter) between the attempts.
+		Retry: &prowl.RetryPolicy{
+			MaxAttempts: 5,
+			BaseDelay:   1 * time.Second,
+			MaxDelay:    10 * time.Second,
+			Jitter:      0.2,
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if _, err := client.Add(prowl.PrioNormal, "Test Event", "Delivered despite a flaky network"); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("sent")
+
+	//output:
+	//sent
+}
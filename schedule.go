@@ -0,0 +1,373 @@
+package prowlgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScheduleConfig enables the scheduler of the client (see Config.Schedule). The scheduler sends
+// notifications at a later time (see Client.SendAt and Client.SendAfter) and sends recurring
+// reminders (see Client.Remind). The notifications are sent by Send when they are due, so
+// recipients, quiet hours, silences and everything else configured for the client apply.
+//
+// A notification is removed from the schedule only after Send succeeded or queued it. If Send
+// fails, the notification is tried again after RetryInterval, which is doubled for every
+// further attempt up to one hour. A reminder that can't be sent before its next time is sent
+// at that time. Notifications rejected with an error matching ErrInvalidArgument, e.g.
+// because a recipient was removed, are dropped.
+//
+// Scheduled notifications are written to Path and survive a restart of the program.
+// Notifications that became due while no client was running are sent as soon as a client
+// with the same Path is created. Reminders skip the times they missed. Only one client must
+// use a path at a time.
+type ScheduleConfig struct {
+	//Path is the file the scheduled notifications are stored in. They are kept in memory only
+	//if Path is empty.
+	Path string
+
+	//RetryInterval is the time before the first retry of a notification that can't be sent.
+	//Defaults to one minute.
+	RetryInterval time.Duration
+}
+
+func (sc *ScheduleConfig) validate() error {
+	if sc.RetryInterval < 0 {
+		return newFieldError("Schedule", "schedule retry interval must not be negative")
+	}
+	if sc.RetryInterval == 0 {
+		sc.RetryInterval = defaultScheduleRetryInterval
+	}
+	return nil
+}
+
+// Scheduled is a notification waiting to be sent by the scheduler. See ScheduleConfig.
+type Scheduled struct {
+	//ID identifies the scheduled notification. See Client.Cancel.
+	ID string
+	//At is the time the notification is sent next.
+	At time.Time
+	//Cron is the cron expression of a reminder. It is empty for notifications sent only once.
+	Cron string
+	//Notification is the notification to send.
+	Notification Notification
+}
+
+const (
+	defaultScheduleRetryInterval = 1 * time.Minute
+	maxScheduleRetryInterval     = 1 * time.Hour
+)
+
+// scheduleFile is the content of ScheduleConfig.Path.
+type scheduleFile struct {
+	Scheduled []Scheduled `json:"scheduled"`
+}
+
+type scheduledItem struct {
+	Scheduled
+	cron *cronSpec
+	//attempts is the number of failed attempts to send the notification.
+	attempts int
+}
+
+// scheduler sends the scheduled notifications of a client when they are due.
+type scheduler struct {
+	clt    *Client
+	config ScheduleConfig
+
+	mu    sync.Mutex
+	items map[string]*scheduledItem
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func openScheduler(clt *Client, config ScheduleConfig) (*scheduler, error) {
+	sc := &scheduler{
+		clt:    clt,
+		config: config,
+		items:  make(map[string]*scheduledItem),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := sc.load(); err != nil {
+		return nil, err
+	}
+	go sc.run()
+	return sc, nil
+}
+
+// load reads the scheduled notifications from the file.
+func (sc *scheduler) load() error {
+	if len(sc.config.Path) == 0 {
+		return nil
+	}
+	buf, err := os.ReadFile(sc.config.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can't read schedule: %w", err)
+	}
+	var sf scheduleFile
+	if err = json.Unmarshal(buf, &sf); err != nil {
+		return fmt.Errorf("can't parse schedule %s: %w", sc.config.Path, err)
+	}
+	now := time.Now()
+	for _, s := range sf.Scheduled {
+		item := &scheduledItem{Scheduled: s}
+		if len(s.Cron) > 0 {
+			if item.cron, err = parseCron(s.Cron); err != nil {
+				return fmt.Errorf("invalid reminder %s in schedule %s: %w", s.ID, sc.config.Path, err)
+			}
+			if item.At.Before(now) {
+				item.At = item.cron.next(now)
+			}
+		}
+		sc.items[s.ID] = item
+	}
+	return nil
+}
+
+// save writes the scheduled notifications to the file. Must be called with sc.mu held.
+func (sc *scheduler) save() error {
+	if len(sc.config.Path) == 0 {
+		return nil
+	}
+	var sf scheduleFile
+	for _, item := range sc.items {
+		sf.Scheduled = append(sf.Scheduled, item.Scheduled)
+	}
+	sort.Slice(sf.Scheduled, func(i, j int) bool { return sf.Scheduled[i].At.Before(sf.Scheduled[j].At) })
+	if err := writeJSONFile(sc.config.Path, sf); err != nil {
+		return fmt.Errorf("can't write schedule: %w", err)
+	}
+	return nil
+}
+
+func (sc *scheduler) add(item *scheduledItem) (id string, err error) {
+	if err = item.Notification.validate(); err != nil {
+		return "", err
+	}
+	if len(item.Notification.To) > 0 || len(item.Notification.Topic) > 0 {
+		if _, err = sc.clt.resolveRecipients(item.Notification.To, item.Notification.Topic); err != nil {
+			return "", err
+		}
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("can't schedule notification: %w", err)
+	}
+	item.ID = hex.EncodeToString(buf)
+	item.Notification.To = append([]string(nil), item.Notification.To...)
+
+	sc.mu.Lock()
+	sc.items[item.ID] = item
+	if err = sc.save(); err != nil {
+		delete(sc.items, item.ID)
+	}
+	sc.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	select {
+	case sc.wake <- struct{}{}:
+	default:
+	}
+	return item.ID, nil
+}
+
+// next returns the time the next notification is due.
+func (sc *scheduler) next() (next time.Time, ok bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	for _, item := range sc.items {
+		if !ok || item.At.Before(next) {
+			next, ok = item.At, true
+		}
+	}
+	return
+}
+
+// due returns the notifications due at the given time in the order they were due. They stay
+// in the schedule until they are settled (see settle).
+func (sc *scheduler) due(now time.Time) (due []Scheduled) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	for _, item := range sc.items {
+		if !item.At.After(now) {
+			due = append(due, item.Scheduled)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].At.Before(due[j].At) })
+	return due
+}
+
+// settle updates the schedule after an attempt to send the notification with the given id.
+// Notifications sent once are removed, reminders are moved to their next time. Notifications
+// that can't be sent are tried again later unless they are invalid or were delivered to some
+// of the recipients.
+func (sc *scheduler) settle(id string, result Result, err error, now time.Time) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	item, ok := sc.items[id]
+	if !ok {
+		//cancelled in the meantime
+		return
+	}
+	failed := err != nil && !errors.Is(err, ErrQueued)
+	if failed && !errors.Is(err, ErrInvalidArgument) && len(result.Delivered) == 0 {
+		item.attempts++
+		retry := (&RetryPolicy{BaseDelay: sc.config.RetryInterval, MaxDelay: maxScheduleRetryInterval}).delay(item.attempts)
+		item.At = now.Add(retry)
+		if item.cron != nil {
+			if next := item.cron.next(now); !next.IsZero() && next.Before(item.At) {
+				item.At, item.attempts = next, 0
+			}
+		}
+		sc.clt.config.Logger.Printf("scheduled prowl message (\"%s\") can't be sent, trying again at %s: %s", item.Notification.Event, item.At.Format(time.RFC3339), err)
+	} else {
+		if failed {
+			sc.clt.config.Logger.Printf("scheduled prowl message (\"%s\") can't be sent: %s", item.Notification.Event, err)
+		}
+		item.attempts = 0
+		if item.cron != nil {
+			item.At = item.cron.next(now)
+		}
+		if item.cron == nil || item.At.IsZero() {
+			delete(sc.items, id)
+		}
+	}
+	if err := sc.save(); err != nil {
+		sc.clt.config.Logger.Printf("prowl schedule can't be saved: %s", err)
+	}
+}
+
+func (sc *scheduler) run() {
+	defer close(sc.done)
+
+	for {
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if next, ok := sc.next(); ok {
+			timer = time.NewTimer(time.Until(next))
+			timeout = timer.C
+		}
+		select {
+		case <-sc.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-sc.wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		for _, s := range sc.due(time.Now()) {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+			result, err := sc.clt.Send(ctx, s.Notification)
+			cancel()
+			sc.settle(s.ID, result, err, time.Now())
+		}
+	}
+}
+
+func (sc *scheduler) close() {
+	select {
+	case <-sc.stop:
+		return
+	default:
+	}
+	close(sc.stop)
+	<-sc.done
+}
+
+// SendAt sends the notification at the given time and returns the id of the scheduled
+// notification. Notifications scheduled for a time in the past are sent right away. Unknown
+// recipients produce an error matching ErrInvalidArgument. The client must have a scheduler
+// (see ScheduleConfig).
+func (clt *Client) SendAt(at time.Time, n Notification) (id string, err error) {
+	if clt.scheduler == nil {
+		return "", newFieldError("Schedule", "client has no scheduler")
+	}
+	return clt.scheduler.add(&scheduledItem{Scheduled: Scheduled{At: at, Notification: n}})
+}
+
+// SendAfter sends the notification after the given duration. See SendAt.
+func (clt *Client) SendAfter(d time.Duration, n Notification) (id string, err error) {
+	return clt.SendAt(time.Now().Add(d), n)
+}
+
+// Remind sends the notification every time the cron expression matches and returns the id of
+// the reminder. The expression has the five fields minute, hour, day of month, month and day
+// of week, e.g. "0 9 * * mon" for every monday at 9:00. Fields accept lists ("1,15"), ranges
+// ("mon-fri") and steps ("*/15"). The shorthands @yearly, @monthly, @weekly, @daily and
+// @hourly can be used instead of the fields. The expression is evaluated in the local time
+// zone unless it starts with the name of a time zone, e.g. "TZ=Europe/Berlin 0 9 1 * *". The
+// client must have a scheduler (see ScheduleConfig).
+func (clt *Client) Remind(cron string, n Notification) (id string, err error) {
+	if clt.scheduler == nil {
+		return "", newFieldError("Schedule", "client has no scheduler")
+	}
+	spec, err := parseCron(cron)
+	if err != nil {
+		return "", newFieldError("cron", "invalid cron expression %q: %s", cron, err)
+	}
+	return clt.scheduler.add(&scheduledItem{Scheduled: Scheduled{At: spec.next(time.Now()), Cron: cron, Notification: n}, cron: spec})
+}
+
+// Cancel removes the scheduled notification or reminder with the given id.
+func (clt *Client) Cancel(id string) error {
+	if clt.scheduler == nil {
+		return newFieldError("Schedule", "client has no scheduler")
+	}
+	sc := clt.scheduler
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	item, ok := sc.items[id]
+	if !ok {
+		return newFieldError("id", "unknown scheduled notification %s", id)
+	}
+	delete(sc.items, id)
+	if err := sc.save(); err != nil {
+		sc.items[id] = item
+		return err
+	}
+	return nil
+}
+
+// Scheduled returns the scheduled notifications and reminders in the order they are due.
+func (clt *Client) Scheduled() []Scheduled {
+	if clt.scheduler == nil {
+		return nil
+	}
+	sc := clt.scheduler
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	ret := make([]Scheduled, 0, len(sc.items))
+	for _, item := range sc.items {
+		s := item.Scheduled
+		s.Notification.To = append([]string(nil), s.Notification.To...)
+		ret = append(ret, s)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].At.Before(ret[j].At) })
+	return ret
+}
@@ -0,0 +1,260 @@
+package prowlgo_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestSendAfter(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	if plain, err := prowl.NewClient(prowl.Config{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := plain.SendAfter(time.Second, prowl.Notification{}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("client without scheduler should produce an error: %v", err)
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:  mock.BaseURL(),
+		APIKeys:  aValidAPIKey,
+		Schedule: &prowl.ScheduleConfig{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.SendAfter(time.Second, prowl.Notification{Priority: 3}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("invalid notification should produce an error: %v", err)
+	}
+	if _, err := client.SendAfter(time.Second, prowl.Notification{To: []string{"nobody"}}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown recipient should produce an error: %v", err)
+	}
+
+	later, err := client.SendAfter(200*time.Millisecond, prowl.Notification{Event: "Later"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := client.SendAfter(100*time.Millisecond, prowl.Notification{Event: "Cancelled"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SendAt(time.Now().Add(-time.Hour), prowl.Notification{Event: "Now"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Cancel(cancelled); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Cancel(cancelled); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown id should produce an error: %v", err)
+	}
+
+	waitFor(t, func() bool { return len(mock.Adds()) == 1 })
+	if scheduled := client.Scheduled(); len(scheduled) != 1 || scheduled[0].ID != later {
+		t.Errorf("unexpected scheduled notifications %+v", scheduled)
+	}
+	waitFor(t, func() bool { return len(mock.Adds()) == 2 })
+	<-time.After(200 * time.Millisecond)
+	adds := mock.Adds()
+	if len(adds) != 2 || adds[0].Params.Get("event") != "Now" || adds[1].Params.Get("event") != "Later" {
+		t.Errorf("unexpected notifications %v", adds)
+	}
+	if scheduled := client.Scheduled(); len(scheduled) != 0 {
+		t.Errorf("unexpected scheduled notifications %+v", scheduled)
+	}
+}
+
+func TestScheduleRetry(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	if _, err := prowl.NewClient(prowl.Config{Schedule: &prowl.ScheduleConfig{RetryInterval: -1}}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("negative retry interval should produce an error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:  mock.BaseURL(),
+		APIKeys:  aValidAPIKey,
+		Schedule: &prowl.ScheduleConfig{Path: path, RetryInterval: 100 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	//notifications that can't be sent stay in the schedule
+	mock.SetOffline(true)
+	id, err := client.SendAfter(0, prowl.Notification{Event: "Retried"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(mock.Requests()) >= 2 })
+	if scheduled := client.Scheduled(); len(scheduled) != 1 || scheduled[0].ID != id || !scheduled[0].At.After(time.Now().Add(-time.Second)) {
+		t.Errorf("unexpected scheduled notifications %+v", scheduled)
+	}
+	if buf, err := os.ReadFile(path); err != nil || !strings.Contains(string(buf), id) {
+		t.Errorf("failed notification should stay in the schedule file: %v", err)
+	}
+
+	mock.SetOffline(false)
+	waitFor(t, func() bool { return len(mock.Adds()) == 1 })
+	waitFor(t, func() bool { return len(client.Scheduled()) == 0 })
+}
+
+func TestRemind(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	client, err := prowl.NewClient(prowl.Config{APIKeys: aValidAPIKey, Schedule: &prowl.ScheduleConfig{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for _, cron := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "0 0 * 13 *", "0 0 * * 8", "0 0 * * fri-mon", "*/0 * * * *", "0 0 30 feb *", "TZ=Nowhere 0 0 * * *", "@fortnightly"} {
+		if _, err := client.Remind(cron, prowl.Notification{Event: "Reminder"}); !errors.Is(err, prowl.ErrInvalidArgument) {
+			t.Errorf("invalid cron expression %q should produce an error: %v", cron, err)
+		}
+	}
+
+	now := time.Now()
+	for _, c := range []struct {
+		cron  string
+		check func(at time.Time) bool
+	}{
+		{"TZ=Europe/Berlin 30 9 * * mon-fri", func(at time.Time) bool {
+			b := at.In(berlin)
+			return b.Hour() == 9 && b.Minute() == 30 && b.Weekday() >= time.Monday && b.Weekday() <= time.Friday && at.Sub(now) <= 4*24*time.Hour
+		}},
+		{"*/15 * * * *", func(at time.Time) bool { return at.Minute()%15 == 0 && at.Sub(now) <= 15*time.Minute }},
+		{"0 0 1,15 * *", func(at time.Time) bool {
+			return (at.Day() == 1 || at.Day() == 15) && at.Hour() == 0 && at.Sub(now) <= 17*24*time.Hour
+		}},
+		{"0 12 * * 7", func(at time.Time) bool {
+			return at.Weekday() == time.Sunday && at.Hour() == 12 && at.Sub(now) <= 7*24*time.Hour
+		}},
+		{"@yearly", func(at time.Time) bool {
+			return at.Month() == time.January && at.Day() == 1 && at.Sub(now) <= 366*24*time.Hour
+		}},
+		//day of month or day of week
+		{"0 0 13 * fri", func(at time.Time) bool {
+			return (at.Day() == 13 || at.Weekday() == time.Friday) && at.Sub(now) <= 7*24*time.Hour
+		}},
+	} {
+		id, err := client.Remind(c.cron, prowl.Notification{Event: "Reminder"})
+		if err != nil {
+			t.Fatalf("%s: %v", c.cron, err)
+		}
+		for _, s := range client.Scheduled() {
+			if s.ID == id && (!s.At.After(now) || s.At.Second() != 0 || !c.check(s.At)) {
+				t.Errorf("%s: unexpected time %s", c.cron, s.At)
+			}
+		}
+	}
+}
+
+func TestSchedulePersistence(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	config := prowl.Config{
+		BaseURL:  mock.BaseURL(),
+		APIKeys:  aValidAPIKey,
+		Schedule: &prowl.ScheduleConfig{Path: path},
+	}
+	client, err := prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SendAfter(100*time.Millisecond, prowl.Notification{Event: "Missed"}); err != nil {
+		t.Fatal(err)
+	}
+	reminder, err := client.Remind("@daily", prowl.Notification{Event: "Check backups"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//notifications missed while no client was running are sent right away
+	<-time.After(200 * time.Millisecond)
+	client, err = prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(mock.Adds()) == 1 })
+	if scheduled := client.Scheduled(); len(scheduled) != 1 || scheduled[0].ID != reminder || scheduled[0].Notification.Event != "Check backups" {
+		t.Errorf("unexpected scheduled notifications %+v", scheduled)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//reminders skip the times they missed
+	if err := os.WriteFile(path, []byte(`{"scheduled": [{"ID": "x", "At": "2020-01-01T00:00:00Z", "Cron": "@daily", "Notification": {"Event": "Rotate certs"}}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err = prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if scheduled := client.Scheduled(); len(scheduled) != 1 || !scheduled[0].At.After(time.Now()) {
+		t.Errorf("unexpected scheduled notifications %+v", scheduled)
+	}
+	<-time.After(100 * time.Millisecond)
+	if n := len(mock.Adds()); n != 1 {
+		t.Errorf("missed reminder should not be sent, got %d notifications", n)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"scheduled": [{"ID": "x", "Cron": "@never"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prowl.NewClient(config); err == nil {
+		t.Error("broken schedule should produce an error")
+	}
+}
+
+func ExampleClient_Remind() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		Application: "prowlgo Example",
+		Schedule:    &prowl.ScheduleConfig{},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	//Clients with a scheduler must be closed.
+	defer client.Close()
+
+	id, err := client.Remind("TZ=Europe/Berlin 0 9 1 */3 *", prowl.Notification{
+		Event:       "Rotate certs",
+		Description: "the certificates of the api servers expire soon",
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	//A reminder is sent until it is cancelled.
+	if err := client.Cancel(id); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(len(client.Scheduled()))
+
+	//output:
+	//0
+}
@@ -0,0 +1,396 @@
+package prowlgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//maxAPIKeysPerRequest is the number of api keys prowl accepts in a single add request.
+	maxAPIKeysPerRequest = 5
+	//maxConcurrentBatches limits the number of add requests sent in parallel.
+	maxConcurrentBatches = 4
+)
+
+// Notification is a message sent to the prowl server by Client.Send.
+type Notification struct {
+	//To lists the names of the recipients (see Config.Recipients) the notification is sent to.
+	//It is sent to all api keys of the client if To is empty.
+	To []string
+	//Topic sends the notification to the subscribers of the topic (see Config.Topics) in
+	//addition to the recipients listed in To. Nothing is sent and the result has
+	//StatusDropped if To is empty and nobody subscribed to the topic.
+	Topic string
+	//Priority of the notification in the range of -2 (PrioVeryLow) to 2 (PrioEmergency).
+	Priority int
+	//Event is the title of the notification. At most 1024 chars.
+	Event string
+	//Description is the message body. At most 10000 chars.
+	Description string
+	//URL is an optional URL the user can open from the prowl app. At most 256 chars.
+	URL string
+	//AppendURL makes the URL also appear at the end of the description.
+	AppendURL bool
+	//DedupKey identifies repeats of the notification if the client has a throttle (see
+	//ThrottleConfig). Defaults to the event.
+	DedupKey string
+}
+
+// Result describes the outcome of Client.Send.
+type Result struct {
+	//Remaining is the number of api calls left.
+	Remaining int
+	//Delivered lists the api keys the notification was delivered to.
+	Delivered []string
+	//Quarantined lists the api keys that have been rejected by the prowl server while sending
+	//this notification. See Client.QuarantinedAPIKeys.
+	Quarantined []string
+	//Status tells if the notification was sent, queued or dropped.
+	Status Status
+	//Quiet lists the api keys the notification was deferred or dropped for because their
+	//recipients are in their quiet hours (see QuietHours).
+	Quiet []string
+	//Incident is the id of the incident if the notification is escalated (see EscalationConfig).
+	Incident string
+	//Silence is the id of the silence that suppressed the notification (see Silence).
+	Silence string
+}
+
+// notification is a validated message on its way to the prowl server.
+type notification struct {
+	Priority    int    `json:"priority"`
+	Event       string `json:"event"`
+	Description string `json:"description"`
+	URL         string `json:"url,omitempty"`
+	//APIKeys are the keys of the recipients. Empty if the notification goes to all keys.
+	APIKeys []string `json:"apikeys,omitempty"`
+	//incident is the id of the incident of an escalated notification (see escalator.settle).
+	incident string
+}
+
+// Send sends the notification to all api keys of the client and reports to which keys it
+// was delivered.
+//
+// Prowl accepts at most 5 api keys per request. If the client holds more keys, they are split
+// into batches (in the order of the keys) which are sent concurrently. If some of the batches
+// fail, the error is returned together with the keys the notification was delivered to.
+//
+// If the prowl server rejects the request as unauthorized, the keys are verified one by one.
+// Rejected keys are quarantined and the notification is sent to the remaining keys. Nothing is
+// quarantined if all keys are valid, i.e. if the provider key was rejected. Send only fails
+// with ErrUnauthorized if there is no valid key left. Quarantined keys are reported in the
+// result.
+func (clt *Client) Send(ctx context.Context, n Notification) (result Result, err error) {
+	result.Remaining = clt.remainingCalls()
+	if err = n.validate(); err != nil {
+		return result, err
+	}
+
+	event := strings.TrimSpace(n.Event)
+	description := strings.TrimSpace(n.Description)
+	withURL := strings.TrimSpace(n.URL)
+
+	if len(withURL) > 0 && n.AppendURL {
+		description = appendText(description, withURL)
+	}
+
+	vn := notification{
+		Priority:    n.Priority,
+		Event:       event,
+		Description: description,
+		URL:         withURL,
+	}
+	if len(n.To) > 0 || len(n.Topic) > 0 {
+		if vn.APIKeys, err = clt.resolveRecipients(n.To, n.Topic); err != nil {
+			return result, err
+		}
+		if len(vn.APIKeys) == 0 {
+			result.Status = StatusDropped
+			return result, nil
+		}
+	}
+
+	if result.Silence = clt.silence(n, vn); len(result.Silence) > 0 {
+		result.Status = StatusSilenced
+		return result, nil
+	}
+
+	if clt.throttle != nil {
+		key := throttleKey(n, vn.APIKeys)
+		ok, suppressed, prev := clt.throttle.pass(key, time.Now())
+		if !ok {
+			result.Status = StatusSuppressed
+			return result, nil
+		}
+		if suppressed > 0 {
+			vn.Description = appendText(vn.Description, suppressedNote(suppressed))
+		}
+		defer func() {
+			if err != nil && !errors.Is(err, ErrQueued) && len(result.Delivered) == 0 {
+				clt.throttle.undo(key, suppressed, prev)
+			}
+		}()
+	}
+
+	//escalated notifications never go into the digest
+	escalate := clt.escalator != nil && escalates(vn.Priority)
+	if !escalate && clt.digest != nil && clt.digest.holds(vn.Priority) {
+		clt.digest.add(vn)
+		result.Status = StatusDigested
+		return result, nil
+	}
+
+	var incident string
+	if escalate {
+		if incident, err = clt.escalator.open(&vn); err != nil {
+			return result, err
+		}
+	}
+	result, err = clt.dispatch(ctx, vn)
+	if len(incident) > 0 && clt.escalator.settle(incident, result, err) {
+		result.Incident = incident
+	}
+	return result, err
+}
+
+func (n Notification) validate() error {
+	if n.Priority < -2 || n.Priority > 2 {
+		return newFieldError("priority", "priority argument must be in the range -2..2")
+	}
+	if len(n.Event) > 1024 {
+		return newFieldError("event", "event argument must not exceed 1024 chars")
+	}
+	if len(n.Description) > 10000 {
+		return newFieldError("description", "description argument must not exceed 10000 chars")
+	}
+	if len(n.URL) > 256 {
+		return newFieldError("withURL", "withURL argument must not exceed 256 chars")
+	}
+	return nil
+}
+
+// appendText appends the text to the description. The description is shortened if necessary.
+func appendText(description string, text string) string {
+	if len(description) == 0 {
+		return text
+	}
+	if len(description)+len(text)+4 > 10000 {
+		description = strings.TrimSpace(truncate(description, 10000-len(text)-4)) + "..."
+	}
+	return description + " " + text
+}
+
+// dispatch holds the validated notification back for recipients in their quiet hours (see
+// QuietHours) and forwards it to all others.
+func (clt *Client) dispatch(ctx context.Context, n notification) (Result, error) {
+	if clt.deferrals != nil {
+		return clt.dispatchQuietly(ctx, n)
+	}
+	return clt.forward(ctx, n)
+}
+
+// forward hands the validated notification to the outbox or sends it right away.
+func (clt *Client) forward(ctx context.Context, n notification) (Result, error) {
+	if clt.outbox != nil {
+		return clt.outbox.add(ctx, n)
+	}
+	return clt.send(ctx, n)
+}
+
+// send delivers the notification to the prowl server. The notification must be valid.
+func (clt *Client) send(ctx context.Context, n notification) (result Result, err error) {
+	clt.mu.Lock()
+	remaining, reset := clt.remaining, clt.reset
+	known, apiKeys := clt.targetAPIKeys(n.APIKeys)
+	clt.mu.Unlock()
+
+	result.Remaining = remaining
+	if known == 0 {
+		return result, newFieldError("APIKeys", "a valid api key is required for add operation")
+	}
+	if len(apiKeys) == 0 {
+		return result, fmt.Errorf("%w: api key(s) are known to be invalid", ErrUnauthorized)
+	}
+	if remaining <= 0 && reset.After(time.Now()) {
+		return result, fmt.Errorf("%w: api requests spent; come back after %s", ErrQuotaExceeded, reset)
+	}
+	//every batch costs an api call
+	batches := splitAPIKeys(apiKeys, maxAPIKeysPerRequest)
+	if err = checkBudget(clt.config.Budget, n.Priority, len(batches), remaining, reset); err != nil {
+		result.Status = StatusDropped
+		return result, err
+	}
+	if err = clt.pace(ctx, len(batches)); err != nil {
+		if errors.Is(err, ErrPaceExceeded) {
+			result.Status = StatusDropped
+		}
+		return result, err
+	}
+	outcomes := make([]batchOutcome, len(batches))
+	if len(batches) == 1 {
+		outcomes[0] = clt.sendBatch(ctx, n, batches[0])
+	} else {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, maxConcurrentBatches)
+		for i, batch := range batches {
+			wg.Add(1)
+			go func(i int, batch []string) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				outcomes[i] = clt.sendBatch(ctx, n, batch)
+			}(i, batch)
+		}
+		wg.Wait()
+	}
+
+	var errs []error
+	var success *Response
+	exceeded := false
+	for i := range outcomes {
+		o := &outcomes[i]
+		result.Delivered = append(result.Delivered, o.delivered...)
+		result.Quarantined = append(result.Quarantined, o.quarantined...)
+		if o.err != nil {
+			errs = append(errs, o.err)
+			exceeded = exceeded || errors.Is(o.err, ErrQuotaExceeded)
+			continue
+		}
+		//the answer with the fewest remaining calls is the most recent one
+		if success == nil || o.response.Success.Remaining < success.Success.Remaining {
+			success = &o.response
+		}
+	}
+	if success != nil && len(batches) > 1 && !exceeded {
+		clt.mu.Lock()
+		clt.remaining = success.Success.Remaining
+		clt.reset = time.Unix(success.Success.Resetdate, 0)
+		clt.mu.Unlock()
+	}
+
+	result.Remaining = clt.remainingCalls()
+	switch len(errs) {
+	case 0:
+		return result, nil
+	case 1:
+		err = errs[0]
+	default:
+		err = errors.Join(errs...)
+	}
+	return result, fmt.Errorf("add request to prowl server failed: %w", err)
+}
+
+// targetAPIKeys returns the number of known keys among targets and the ones which are not
+// quarantined. All keys of the client are targeted if targets is empty.
+// Must be called with clt.mu held.
+func (clt *Client) targetAPIKeys(targets []string) (known int, active []string) {
+	if len(targets) == 0 {
+		return len(clt.apiKeys), clt.activeAPIKeys()
+	}
+	for _, key := range targets {
+		if valid, ok := clt.apiKeys[key]; ok {
+			known++
+			if valid {
+				active = append(active, key)
+			}
+		}
+	}
+	return
+}
+
+// batchOutcome is the outcome of sending a notification to a batch of api keys.
+type batchOutcome struct {
+	delivered   []string
+	quarantined []string
+	response    Response
+	err         error
+}
+
+// sendBatch sends the notification to a batch of api keys. Rejected keys are sorted out
+// and the notification is sent again to the remaining keys of the batch.
+func (clt *Client) sendBatch(ctx context.Context, n notification, apiKeys []string) (o batchOutcome) {
+	o.response, o.err = clt.post(ctx, n, apiKeys)
+	if errors.Is(o.err, ErrUnauthorized) {
+		var valid []string
+		if valid, o.quarantined, o.err = clt.sortOutAPIKeys(ctx, apiKeys, o.err); len(valid) > 0 {
+			apiKeys = valid
+			o.response, o.err = clt.post(ctx, n, apiKeys)
+		}
+	}
+	if o.err == nil {
+		o.delivered = apiKeys
+	}
+	return
+}
+
+// splitAPIKeys splits the keys into batches of at most size keys.
+func splitAPIKeys(apiKeys []string, size int) (batches [][]string) {
+	for len(apiKeys) > size {
+		batches = append(batches, apiKeys[:size:size])
+		apiKeys = apiKeys[size:]
+	}
+	return append(batches, apiKeys)
+}
+
+// post sends a single add request for the given api keys.
+func (clt *Client) post(ctx context.Context, n notification, apiKeys []string) (Response, error) {
+	data := url.Values{
+		"apikey":      {strings.Join(apiKeys, ",")},
+		"providerkey": {clt.config.ProviderKey},
+		"priority":    {fmt.Sprintf("%d", n.Priority)},
+		"application": {clt.config.Application},
+		"event":       {n.Event},
+		"description": {n.Description},
+		"url":         {n.URL},
+	}
+	response, err := clt.request(ctx, "add", func() (*http.Response, error) {
+		return clt.postForm(ctx, clt.endpoint(addPath), data)
+	})
+
+	if response.Error.Code == 406 {
+		clt.mu.Lock()
+		clt.remaining = 0
+		clt.mu.Unlock()
+	}
+	return response, err
+}
+
+// sortOutAPIKeys is called when the prowl server rejected a request for apiKeys. It verifies
+// the keys one by one, without the provider key, and quarantines the rejected ones. If the
+// keys can't be verified or all of them are valid, nothing is quarantined and the original
+// error is returned. In the latter case the provider key was rejected.
+func (clt *Client) sortOutAPIKeys(ctx context.Context, apiKeys []string, rejected error) (valid []string, invalid []string, err error) {
+	for _, key := range apiKeys {
+		_, verr := clt.verify(ctx, key, false)
+		switch {
+		case verr == nil:
+			valid = append(valid, key)
+		case errors.Is(verr, ErrUnauthorized):
+			invalid = append(invalid, key)
+		default:
+			return nil, nil, rejected
+		}
+	}
+	if len(invalid) == 0 {
+		return nil, nil, rejected
+	}
+
+	clt.mu.Lock()
+	for _, key := range invalid {
+		if _, ok := clt.apiKeys[key]; ok {
+			clt.apiKeys[key] = false
+		}
+	}
+	clt.mu.Unlock()
+
+	if len(valid) == 0 {
+		return nil, invalid, rejected
+	}
+	return valid, invalid, nil
+}
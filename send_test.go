@@ -0,0 +1,233 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	prowl "github.com/tweithoener/prowlgo"
+	"github.com/tweithoener/prowlgo/prowltest"
+)
+
+func TestSendQuarantine(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: multipleValidAPIKeys,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := multipleValidAPIKeys[1]
+
+	if _, err := client.Send(context.Background(), prowl.Notification{Priority: 3}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Error("invalid priority should produce an error")
+	}
+
+	//one of the keys is rejected: it is quarantined and the others still get the notification
+	mock.RejectAPIKeys(bad)
+	result, err := client.Send(context.Background(), prowl.Notification{Event: "Event", Description: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Quarantined, []string{bad}) || len(result.Delivered) != 2 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	for _, key := range result.Delivered {
+		if key == bad {
+			t.Error("rejected key reported as delivered")
+		}
+	}
+	if !reflect.DeepEqual(client.QuarantinedAPIKeys(), []string{bad}) {
+		t.Errorf("unexpected quarantined keys %v", client.QuarantinedAPIKeys())
+	}
+	if len(client.Config().APIKeys) != 3 {
+		t.Error("quarantined keys should remain in config")
+	}
+
+	//the quarantined key is no longer used
+	mock.Reset()
+	mock.RejectAPIKeys(bad)
+	result, err = client.Send(context.Background(), prowl.Notification{Event: "Event", Description: "2"})
+	if err != nil || len(result.Delivered) != 2 || len(result.Quarantined) != 0 {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+	if n := len(mock.Requests()); n != 1 {
+		t.Errorf("expected a single request, got %d", n)
+	}
+
+	//adding the key again releases it from quarantine
+	mock.Reset()
+	if err := client.AddAPIKey(bad); err != nil {
+		t.Fatal(err)
+	}
+	result, err = client.Send(context.Background(), prowl.Notification{Event: "Event", Description: "3"})
+	if err != nil || len(result.Delivered) != 3 || len(client.QuarantinedAPIKeys()) != 0 {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+
+	//keys that can't be verified are not quarantined: the quota is spent during verification
+	mock.RejectAPIKeys(bad)
+	mock.SetRemaining(1)
+	client, err = prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: multipleValidAPIKeys,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Send(context.Background(), prowl.Notification{Event: "Event"}); !errors.Is(err, prowl.ErrUnauthorized) {
+		t.Errorf("unauthorized expected: %v", err)
+	}
+	if len(client.QuarantinedAPIKeys()) != 0 {
+		t.Error("no key should be quarantined if keys can't be verified")
+	}
+
+	//all keys are rejected
+	mock.Reset()
+	mock.SetAcceptAPIKeys(false)
+	client, err = prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: multipleValidAPIKeys,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = client.Send(context.Background(), prowl.Notification{Event: "Event"})
+	if !errors.Is(err, prowl.ErrUnauthorized) || len(result.Quarantined) != 3 || len(result.Delivered) != 0 {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+	requests := len(mock.Requests())
+	if _, err := client.Send(context.Background(), prowl.Notification{Event: "Event"}); !errors.Is(err, prowl.ErrUnauthorized) {
+		t.Errorf("unauthorized expected: %v", err)
+	}
+	if len(mock.Requests()) != requests {
+		t.Error("no request should be sent if all keys are quarantined")
+	}
+
+	//a rejected provider key does not quarantine valid api keys, not even a single one
+	for _, keys := range [][]string{aValidAPIKey, multipleValidAPIKeys} {
+		mock.Reset()
+		mock.SetAcceptProviderKey(false)
+		client, err = prowl.NewClient(prowl.Config{
+			BaseURL:     mock.BaseURL(),
+			APIKeys:     keys,
+			ProviderKey: aValidProviderKey,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Send(context.Background(), prowl.Notification{Event: "Event"}); !errors.Is(err, prowl.ErrUnauthorized) {
+			t.Errorf("unauthorized expected: %v", err)
+		}
+		if quarantined := client.QuarantinedAPIKeys(); len(quarantined) != 0 {
+			t.Errorf("no key should be quarantined if the provider key is rejected: %v", quarantined)
+		}
+	}
+}
+
+func TestSendBatches(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	var keys []string
+	for i := 0; i < 12; i++ {
+		keys = append(keys, fmt.Sprintf("%040d", 11-i))
+	}
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: keys,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+
+	//12 keys are sent in 3 requests with at most 5 keys each
+	result, err := client.Send(context.Background(), prowl.Notification{Event: "Event"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Delivered, sorted) {
+		t.Errorf("unexpected delivered keys %v", result.Delivered)
+	}
+	if result.Remaining != prowltest.DefaultRemaining-3 || client.Reset().IsZero() {
+		t.Errorf("unexpected remaining calls %d", result.Remaining)
+	}
+	adds := mock.Adds()
+	if len(adds) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(adds))
+	}
+	var sent []string
+	for _, add := range adds {
+		if len(add.APIKeys()) > 5 {
+			t.Errorf("too many keys in one request: %d", len(add.APIKeys()))
+		}
+		sent = append(sent, add.APIKeys()...)
+	}
+	sort.Strings(sent)
+	if !reflect.DeepEqual(sent, sorted) {
+		t.Errorf("unexpected keys sent %v", sent)
+	}
+
+	//a rejected key only affects its own batch
+	mock.Reset()
+	mock.RejectAPIKeys(sorted[7])
+	result, err = client.Send(context.Background(), prowl.Notification{Event: "Event"})
+	if err != nil || len(result.Delivered) != 11 || !reflect.DeepEqual(result.Quarantined, []string{sorted[7]}) {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+	verified := 0
+	for _, req := range mock.Requests() {
+		if req.Operation == "verify" {
+			verified++
+		}
+	}
+	if verified != 5 {
+		t.Errorf("expected the 5 keys of one batch to be verified, got %d", verified)
+	}
+
+	//a failing batch does not affect the others
+	mock.Reset()
+	mock.FailNext(1)
+	result, err = client.Send(context.Background(), prowl.Notification{Event: "Event"})
+	if err == nil || len(result.Delivered) == 0 || len(result.Delivered) == 11 {
+		t.Errorf("unexpected result %+v: %v", result, err)
+	}
+}
+
+func ExampleClient_Send() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     multipleValidAPIKeys,
+		Application: "prowlgo Example",
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	result, err := client.Send(context.Background(), prowl.Notification{
+		Priority:    prowl.PrioHigh,
+		Event:       "Disk full",
+		Description: "/dev/sda1 is full",
+		URL:         "https://example.com/disks",
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("delivered to", len(result.Delivered), "devices")
+	for _, key := range result.Quarantined {
+		fmt.Println("api key rejected:", key)
+	}
+
+	//output:
+	//delivered to 3 devices
+}
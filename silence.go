@@ -0,0 +1,415 @@
+package prowlgo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	//silenceRetention is the time expired silences are kept.
+	silenceRetention = 5 * 24 * time.Hour
+	//maxSilenced is the number of suppressed notifications recorded per silence.
+	maxSilenced = 100
+	//lockTimeout is the time to wait for the lock file of a file shared by several clients.
+	lockTimeout = 5 * time.Second
+	//staleLockAge is the age of a lock file that was left behind by a crashed client.
+	staleLockAge = 30 * time.Second
+)
+
+// Silence suppresses notifications for some time, e.g. during planned maintenance. Send does
+// not send a notification that matches an active silence but records it (see Client.Silenced)
+// and reports it with StatusSilenced.
+//
+// A notification matches a silence if its event matches the regular expression Event, the
+// application of the client (see Config.Application) matches the regular expression
+// Application and its priority is in the range MinPriority..MaxPriority. The regular
+// expressions must match the whole event or application name. Empty expressions match
+// everything.
+//
+// Silences are persisted to Config.SilenceFile. Clients using the same file share their
+// silences: a silence added by one client (or by "prowl silence add") is picked up by all
+// others. Changes are made while holding a lock file next to the silence file (its name with
+// ".lock" appended), so clients changing silences at the same time don't lose each other's
+// changes. The notifications suppressed by a silence are kept in memory only. Expired
+// silences are removed after five days.
+type Silence struct {
+	//ID identifies the silence. It is assigned by AddSilence.
+	ID string
+	//Event is a regular expression matching the event of the notifications, e.g. "DB.*".
+	Event string
+	//Application is a regular expression matching the application of the client.
+	Application string
+	//MinPriority and MaxPriority are the range of the priorities of the notifications to
+	//silence. If both are zero, all priorities are silenced. A silence can't be limited to
+	//PrioNormal alone.
+	MinPriority int
+	MaxPriority int
+	//Start is the time the silence starts. Defaults to the time it is added.
+	Start time.Time
+	//End is the time the silence ends.
+	End time.Time
+	//CreatedBy names who added the silence.
+	CreatedBy string
+	//Comment tells why the silence was added.
+	Comment string
+}
+
+// Active reports whether the silence is in effect at the given time.
+func (s Silence) Active(at time.Time) bool {
+	return !at.Before(s.Start) && at.Before(s.End)
+}
+
+// silence is a validated Silence.
+type silence struct {
+	Silence
+	event       *regexp.Regexp
+	application *regexp.Regexp
+	recorded    []Recorded
+}
+
+func newSilence(s Silence) (*silence, error) {
+	event, err := compileMatcher(s.Event)
+	if err != nil {
+		return nil, newFieldError("silence", "invalid event expression of silence: %s", err)
+	}
+	application, err := compileMatcher(s.Application)
+	if err != nil {
+		return nil, newFieldError("silence", "invalid application expression of silence: %s", err)
+	}
+	//the zero values are an unset range
+	if s.MinPriority == 0 && s.MaxPriority == 0 {
+		s.MinPriority, s.MaxPriority = PrioVeryLow, PrioEmergency
+	}
+	if s.MinPriority < PrioVeryLow || s.MaxPriority > PrioEmergency || s.MinPriority > s.MaxPriority {
+		return nil, newFieldError("silence", "priorities of silence must be a range within -2..2")
+	}
+	if !s.End.After(s.Start) {
+		return nil, newFieldError("silence", "silence must end after its start")
+	}
+	return &silence{Silence: s, event: event, application: application}, nil
+}
+
+// compileMatcher compiles an expression that must match the whole text. Empty expressions
+// match everything.
+func compileMatcher(expr string) (*regexp.Regexp, error) {
+	if len(expr) == 0 {
+		expr = "(?s:.*)"
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+func (s *silence) matches(n notification, application string) bool {
+	return n.Priority >= s.MinPriority && n.Priority <= s.MaxPriority &&
+		s.event.MatchString(n.Event) && s.application.MatchString(application)
+}
+
+// silenceFile is the content of Config.SilenceFile.
+type silenceFile struct {
+	Silences []Silence `json:"silences"`
+}
+
+// silences holds the silences of a client and keeps them in sync with the silence file.
+type silences struct {
+	path string
+
+	mu   sync.Mutex
+	list []*silence
+	//modTime and size of the silence file when it was read or written last.
+	modTime time.Time
+	size    int64
+}
+
+func openSilences(path string) (*silences, error) {
+	ss := &silences{path: path}
+	if len(path) == 0 {
+		return ss, nil
+	}
+	if err := ss.refresh(); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+// refresh reads the silence file again if it changed. The suppressed notifications recorded
+// so far are kept. Must be called with ss.mu held.
+func (ss *silences) refresh() error {
+	if len(ss.path) == 0 {
+		return nil
+	}
+	info, err := os.Stat(ss.path)
+	if os.IsNotExist(err) {
+		//the silences are gone if the file was removed
+		ss.list, ss.modTime, ss.size = nil, time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can't read silence file: %w", err)
+	}
+	if info.ModTime().Equal(ss.modTime) && info.Size() == ss.size {
+		return nil
+	}
+
+	buf, err := os.ReadFile(ss.path)
+	if err != nil {
+		return fmt.Errorf("can't read silence file: %w", err)
+	}
+	//a broken file is reported once, the silences read before stay in effect
+	ss.modTime, ss.size = info.ModTime(), info.Size()
+	var sf silenceFile
+	if err = json.Unmarshal(buf, &sf); err != nil {
+		return fmt.Errorf("can't parse silence file %s: %w", ss.path, err)
+	}
+	recorded := make(map[string][]Recorded, len(ss.list))
+	for _, s := range ss.list {
+		recorded[s.ID] = s.recorded
+	}
+	list := make([]*silence, 0, len(sf.Silences))
+	for _, s := range sf.Silences {
+		vs, err := newSilence(s)
+		if err != nil {
+			return fmt.Errorf("invalid silence %s in silence file %s: %w", s.ID, ss.path, err)
+		}
+		vs.recorded = recorded[s.ID]
+		list = append(list, vs)
+	}
+	ss.list = list
+	return nil
+}
+
+// save writes the silences to the silence file. Expired silences past their retention are
+// dropped. The file is replaced atomically. Must be called with ss.mu held.
+func (ss *silences) save(now time.Time) error {
+	list := ss.list[:0]
+	for _, s := range ss.list {
+		if now.Sub(s.End) < silenceRetention {
+			list = append(list, s)
+		}
+	}
+	ss.list = list
+	if len(ss.path) == 0 {
+		return nil
+	}
+
+	var sf silenceFile
+	for _, s := range ss.list {
+		sf.Silences = append(sf.Silences, s.Silence)
+	}
+	if err := writeJSONFile(ss.path, sf); err != nil {
+		return fmt.Errorf("can't write silence file: %w", err)
+	}
+	if info, err := os.Stat(ss.path); err == nil {
+		ss.modTime, ss.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// update applies the change to the silences in the silence file and saves them. The silence
+// file is locked meanwhile, so that the changes of other clients are not lost. Must be called
+// with ss.mu held.
+func (ss *silences) update(now time.Time, change func() error) (err error) {
+	if len(ss.path) > 0 {
+		unlock, err := lockFile(ss.path, lockTimeout)
+		if err != nil {
+			return fmt.Errorf("can't lock silence file: %w", err)
+		}
+		defer unlock()
+		//the modification time might be too coarse to tell if another client changed the file
+		ss.modTime, ss.size = time.Time{}, 0
+	}
+	if err = ss.refresh(); err != nil {
+		return err
+	}
+	if err = change(); err != nil {
+		return err
+	}
+	if err = ss.save(now); err != nil {
+		//read the file again, the change did not make it
+		ss.modTime, ss.size = time.Time{}, 0
+	}
+	return err
+}
+
+// find returns the silence with the given id. Must be called with ss.mu held.
+func (ss *silences) find(id string) *silence {
+	for _, s := range ss.list {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// silence records the notification with the first active silence it matches and returns the
+// id of the silence. It returns an empty id if the notification is not silenced.
+func (clt *Client) silence(n Notification, vn notification) string {
+	ss := clt.silences
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if err := ss.refresh(); err != nil {
+		clt.config.Logger.Printf("prowl silences can't be updated: %s", err)
+	}
+	now := time.Now()
+	for _, s := range ss.list {
+		if !s.Active(now) || !s.matches(vn, clt.config.Application) {
+			continue
+		}
+		if len(s.recorded) >= maxSilenced {
+			s.recorded = s.recorded[1:]
+		}
+		s.recorded = append(s.recorded, Recorded{To: n.To, Topic: n.Topic, Priority: n.Priority, Event: n.Event, Description: n.Description, URL: n.URL, AppendURL: n.AppendURL, DedupKey: n.DedupKey})
+		return s.ID
+	}
+	return ""
+}
+
+// AddSilence adds a silence and returns its id. The silence is written to the silence file
+// (see Config.SilenceFile) before AddSilence returns.
+func (clt *Client) AddSilence(s Silence) (id string, err error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("can't create silence: %w", err)
+	}
+	s.ID = hex.EncodeToString(buf)
+	now := time.Now()
+	if s.Start.IsZero() {
+		s.Start = now
+	}
+	vs, err := newSilence(s)
+	if err != nil {
+		return "", err
+	}
+
+	ss := clt.silences
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	err = ss.update(now, func() error {
+		ss.list = append(ss.list, vs)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return vs.ID, nil
+}
+
+// ExpireSilence ends the silence with the given id right away. Silences that have already
+// expired are left alone.
+func (clt *Client) ExpireSilence(id string) error {
+	ss := clt.silences
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	now := time.Now()
+	return ss.update(now, func() error {
+		s := ss.find(id)
+		if s == nil {
+			return newFieldError("silence", "unknown silence %s", id)
+		}
+		if !s.End.After(now) {
+			return nil
+		}
+		s.End = now
+		if s.Start.After(now) {
+			s.Start = now
+		}
+		return nil
+	})
+}
+
+// Silences returns all silences including the expired ones, ordered by their start.
+func (clt *Client) Silences() ([]Silence, error) {
+	ss := clt.silences
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if err := ss.refresh(); err != nil {
+		return nil, err
+	}
+	ret := make([]Silence, 0, len(ss.list))
+	for _, s := range ss.list {
+		ret = append(ret, s.Silence)
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Start.Before(ret[j].Start) })
+	return ret, nil
+}
+
+// Silenced returns the notifications suppressed by the silence with the given id, at most
+// the last 100.
+func (clt *Client) Silenced(id string) ([]Recorded, error) {
+	ss := clt.silences
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	s := ss.find(id)
+	if s == nil {
+		return nil, newFieldError("silence", "unknown silence %s", id)
+	}
+	return append([]Recorded(nil), s.recorded...), nil
+}
+
+// writeJSONFile writes v to the file at path. The directory is created if necessary. The file
+// is replaced atomically so that it is never left half written.
+func writeJSONFile(path string, v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	//a temporary file of its own keeps concurrent writers from clobbering each other
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(buf, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// lockFile creates the lock file of the file at path. It waits up to timeout while another
+// client holds the lock. Lock files older than staleLockAge are removed. The returned function
+// releases the lock.
+func lockFile(path string, timeout time.Duration) (unlock func(), err error) {
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	lock := path + ".lock"
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by another client", lock)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,233 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestSilence(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		Application: "billing",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, s := range []prowl.Silence{
+		{Event: "(", End: now.Add(time.Hour)},
+		{Application: "[", End: now.Add(time.Hour)},
+		{MinPriority: prowl.PrioHigh, MaxPriority: prowl.PrioNormal, End: now.Add(time.Hour)},
+		{MaxPriority: 3, End: now.Add(time.Hour)},
+		{MaxPriority: prowl.PrioHigh},
+	} {
+		if _, err := client.AddSilence(s); !errors.Is(err, prowl.ErrInvalidArgument) {
+			t.Errorf("invalid silence %+v should produce an error: %v", s, err)
+		}
+	}
+
+	db, err := client.AddSilence(prowl.Silence{Event: "DB.*", MinPriority: prowl.PrioVeryLow, MaxPriority: prowl.PrioHigh, End: now.Add(time.Hour), Comment: "maintenance"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AddSilence(prowl.Silence{Application: "shop", MinPriority: prowl.PrioVeryLow, MaxPriority: prowl.PrioEmergency, End: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AddSilence(prowl.Silence{Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if silences, _ := client.Silences(); silences[2].MinPriority != prowl.PrioVeryLow || silences[2].MaxPriority != prowl.PrioEmergency {
+		t.Errorf("unset priorities should silence all priorities: %+v", silences[2])
+	}
+
+	for _, c := range []struct {
+		priority int
+		event    string
+		silenced bool
+	}{
+		{prowl.PrioNormal, "DB backup", true},
+		{prowl.PrioHigh, "DB down", true},
+		{prowl.PrioEmergency, "DB down", false},
+		{prowl.PrioNormal, "my DB", false},
+		{prowl.PrioNormal, "Disk full", false},
+	} {
+		result, err := client.Send(context.Background(), prowl.Notification{Priority: c.priority, Event: c.event})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if silenced := result.Status == prowl.StatusSilenced; silenced != c.silenced || (silenced && result.Silence != db) {
+			t.Errorf("%s (priority %d): unexpected result %+v", c.event, c.priority, result)
+		}
+	}
+	if n := len(mock.Adds()); n != 3 {
+		t.Errorf("unexpected number of notifications %d", n)
+	}
+	recorded, err := client.Silenced(db)
+	if err != nil || len(recorded) != 2 || recorded[1].Event != "DB down" {
+		t.Errorf("silenced notifications should be recorded %+v: %v", recorded, err)
+	}
+
+	//expired silences are kept but do not silence anything
+	if err := client.ExpireSilence(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ExpireSilence("nope"); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown silence should produce an error: %v", err)
+	}
+	if result, err := client.Send(context.Background(), prowl.Notification{Event: "DB backup"}); err != nil || result.Status != prowl.StatusSent {
+		t.Errorf("notification should be sent %+v: %v", result, err)
+	}
+	silences, err := client.Silences()
+	if err != nil || len(silences) != 3 || silences[0].ID != db || silences[0].Active(time.Now()) {
+		t.Errorf("unexpected silences %+v: %v", silences, err)
+	}
+}
+
+func TestSilenceFile(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	path := filepath.Join(t.TempDir(), "silences", "silences.json")
+	config := prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		SilenceFile: path,
+	}
+	client, err := prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//silences added by one client are picked up by the other
+	id, err := other.AddSilence(prowl.Silence{MaxPriority: prowl.PrioHigh, End: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := client.Send(context.Background(), prowl.Notification{Event: "Event"}); err != nil || result.Silence != id {
+		t.Errorf("notification should be silenced %+v: %v", result, err)
+	}
+	if err := other.ExpireSilence(id); err != nil {
+		t.Fatal(err)
+	}
+	//make sure the change is visible even if the file system has a coarse time resolution
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := client.Send(context.Background(), prowl.Notification{Event: "Event"}); err != nil || result.Status != prowl.StatusSent {
+		t.Errorf("notification should be sent %+v: %v", result, err)
+	}
+
+	//a new client reads the file
+	restarted, err := prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if silences, err := restarted.Silences(); err != nil || len(silences) != 1 || silences[0].ID != id {
+		t.Errorf("unexpected silences %+v: %v", silences, err)
+	}
+
+	if err := os.WriteFile(path, []byte("no json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prowl.NewClient(config); err == nil {
+		t.Error("broken silence file should produce an error")
+	}
+}
+
+func TestSilenceFileConcurrent(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "silences")
+	path := filepath.Join(dir, "silences.json")
+	config := prowl.Config{APIKeys: aValidAPIKey, SilenceFile: path}
+
+	//clients sharing the file don't lose each other's silences
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		client, err := prowl.NewClient(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if _, err := client.AddSilence(prowl.Silence{End: time.Now().Add(time.Hour)}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	client, err := prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if silences, err := client.Silences(); err != nil || len(silences) != 20 {
+		t.Errorf("expected 20 silences, got %d: %v", len(silences), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary files or locks left behind: %v", entries)
+	}
+
+	//a lock left behind by a crashed client is broken
+	if err := os.WriteFile(path+".lock", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AddSilence(prowl.Silence{End: time.Now().Add(time.Hour)}); err != nil {
+		t.Errorf("stale lock should be broken: %v", err)
+	}
+}
+
+func ExampleClient_AddSilence() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		Application: "prowlgo Example",
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	_, err = client.AddSilence(prowl.Silence{
+		Event:       "DB.*",
+		MinPriority: prowl.PrioVeryLow,
+		MaxPriority: prowl.PrioHigh,
+		End:         time.Now().Add(2 * time.Hour),
+		Comment:     "database maintenance",
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	result, err := client.Send(context.Background(), prowl.Notification{Event: "DB replication", Description: "lag 300s"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(result.Status)
+
+	//output:
+	//silenced
+}
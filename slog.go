@@ -0,0 +1,187 @@
+package prowlgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SlogHandlerOptions configures a SlogHandler.
+type SlogHandlerOptions struct {
+	//Level is the minimum level of records that are sent to prowl. Defaults to slog.LevelError.
+	//Records below this level are passed to the wrapped handler only.
+	Level slog.Leveler
+
+	//Sync makes Handle block until the prowl server answered. By default records are sent
+	//in the background and errors are reported to the wrapped handler as warnings.
+	Sync bool
+
+	//Timeout limits the time spent sending a record in the background. Defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// SlogHandler is a slog.Handler that passes all records to another handler and in addition
+// sends records at or above a configurable level to a Notifier (usually a Client). The message
+// of the record becomes the event and its attributes are rendered into the description, one
+// key=value pair per line. The priority is derived from the level of the record
+// (see PriorityForLevel).
+type SlogHandler struct {
+	next     slog.Handler
+	notifier Notifier
+	level    slog.Leveler
+	sync     bool
+	timeout  time.Duration
+	attrs    []string
+	group    string
+}
+
+// NewSlogHandler creates a SlogHandler which wraps next and sends to prowl using notifier.
+// opts may be nil to use the defaults.
+func NewSlogHandler(next slog.Handler, notifier Notifier, opts *SlogHandlerOptions) *SlogHandler {
+	h := &SlogHandler{
+		next:     next,
+		notifier: notifier,
+		level:    slog.LevelError,
+		timeout:  defaultTimeout,
+	}
+	if opts != nil {
+		if opts.Level != nil {
+			h.level = opts.Level
+		}
+		if opts.Timeout > 0 {
+			h.timeout = opts.Timeout
+		}
+		h.sync = opts.Sync
+	}
+	return h
+}
+
+// PriorityForLevel maps slog levels to prowl priorities:
+//
+//	level >= LevelError+4   PrioEmergency
+//	level >= LevelError     PrioHigh
+//	level >= LevelWarn      PrioNormal
+//	level >= LevelInfo      PrioModerate
+//	below                   PrioVeryLow
+func PriorityForLevel(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError+4:
+		return PrioEmergency
+	case level >= slog.LevelError:
+		return PrioHigh
+	case level >= slog.LevelWarn:
+		return PrioNormal
+	case level >= slog.LevelInfo:
+		return PrioModerate
+	}
+	return PrioVeryLow
+}
+
+// Enabled reports whether either the wrapped handler or prowl is interested in records
+// of the given level.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() || h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record to the wrapped handler and sends it to prowl if its level
+// is high enough.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	if h.next.Enabled(ctx, r.Level) {
+		err = h.next.Handle(ctx, r)
+	}
+	if r.Level < h.level.Level() {
+		return err
+	}
+
+	lines := append([]string{}, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		lines = appendAttr(lines, h.group, a)
+		return true
+	})
+	description := strings.Join(lines, "\n")
+	if len(description) > 10000 {
+		description = strings.TrimSpace(truncate(description, 9997)) + "..."
+	}
+	event := r.Message
+	if len(event) > 1024 {
+		event = strings.TrimSpace(truncate(event, 1021)) + "..."
+	}
+	prio := PriorityForLevel(r.Level)
+
+	if h.sync {
+		if _, serr := h.notifier.AddContext(ctx, prio, event, description); serr != nil {
+			err = errors.Join(err, serr)
+		}
+		return err
+	}
+
+	go func() {
+		sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.timeout)
+		defer cancel()
+		if _, serr := h.notifier.AddContext(sctx, prio, event, description); serr != nil {
+			fail := slog.NewRecord(time.Now(), slog.LevelWarn, "can't send prowl message", 0)
+			fail.AddAttrs(slog.String("event", event), slog.String("error", serr.Error()))
+			if h.next.Enabled(sctx, fail.Level) {
+				h.next.Handle(sctx, fail)
+			}
+		}
+	}()
+	return err
+}
+
+// WithAttrs returns a handler that adds attrs to every record.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cpy := *h
+	cpy.next = h.next.WithAttrs(attrs)
+	cpy.attrs = append([]string{}, h.attrs...)
+	for _, a := range attrs {
+		cpy.attrs = appendAttr(cpy.attrs, h.group, a)
+	}
+	return &cpy
+}
+
+// WithGroup returns a handler that puts all following attributes into the named group.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	cpy := *h
+	cpy.next = h.next.WithGroup(name)
+	cpy.group = h.group + name + "."
+	return &cpy
+}
+
+// appendAttr renders the attribute as key=value lines. Groups are flattened using
+// dotted keys.
+func appendAttr(lines []string, prefix string, a slog.Attr) []string {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return lines
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix = prefix + a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			lines = appendAttr(lines, prefix, ga)
+		}
+		return lines
+	}
+	return append(lines, fmt.Sprintf("%s%s=%s", prefix, a.Key, a.Value))
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 encoded character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
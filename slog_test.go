@@ -0,0 +1,124 @@
+package prowlgo_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestSlogHandler(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL: mock.BaseURL(),
+		APIKeys: aValidAPIKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	next := slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	logger := slog.New(prowl.NewSlogHandler(next, client, &prowl.SlogHandlerOptions{
+		Level: slog.LevelWarn,
+		Sync:  true,
+	}))
+
+	//below the level: only logged
+	logger.Info("just info", "key", "value")
+	if len(mock.Requests()) != 0 {
+		t.Error("info record should not be sent to prowl")
+	}
+	if !strings.Contains(buf.String(), "just info") {
+		t.Error("info record should be passed to the wrapped handler")
+	}
+
+	//at or above the level: logged and sent
+	logger.With("service", "db").WithGroup("req").Error("query failed", "id", 42, slog.Group("user", "name", "alice"))
+	if len(mock.Requests()) != 1 {
+		t.Error("error record should be sent to prowl")
+	}
+	if !strings.Contains(buf.String(), "query failed") {
+		t.Error("error record should be passed to the wrapped handler")
+	}
+	if _, descr := lastAdd(); descr != "service=db\nreq.id=42\nreq.user.name=alice" {
+		t.Errorf("unexpected description %q", descr)
+	}
+
+	//long records are shortened without splitting characters
+	logger.Error(strings.Repeat("ä", 600), "text", strings.Repeat("€", 4000))
+	if last, _ := mock.LastAdd(); len(last.Params.Get("event")) > 1024 || len(last.Params.Get("description")) > 10000 ||
+		!utf8.ValidString(last.Params.Get("event")) || !utf8.ValidString(last.Params.Get("description")) {
+		t.Errorf("unexpected notification %d/%d chars", len(last.Params.Get("event")), len(last.Params.Get("description")))
+	}
+
+	//failing to send is reported by synchronous handlers
+	mock.SetAcceptAPIKeys(false)
+	h := prowl.NewSlogHandler(next, client, &prowl.SlogHandlerOptions{Level: slog.LevelWarn, Sync: true})
+	logger = slog.New(h)
+	logger.Warn("rejected")
+	if !strings.Contains(buf.String(), "rejected") {
+		t.Error("record should be passed to the wrapped handler even if sending fails")
+	}
+
+	//a handler with the defaults only sends errors but logs whatever next wants
+	h = prowl.NewSlogHandler(slog.NewTextHandler(buf, nil), client, nil)
+	if h.Enabled(context.Background(), slog.LevelDebug) || !h.Enabled(context.Background(), slog.LevelInfo) || !h.Enabled(context.Background(), slog.LevelError) {
+		t.Error("handler enabled for unexpected levels")
+	}
+}
+
+func TestPriorityForLevel(t *testing.T) {
+	cases := map[slog.Level]int{
+		slog.LevelDebug:     prowl.PrioVeryLow,
+		slog.LevelInfo:      prowl.PrioModerate,
+		slog.LevelWarn:      prowl.PrioNormal,
+		slog.LevelError:     prowl.PrioHigh,
+		slog.LevelError + 4: prowl.PrioEmergency,
+		slog.LevelError + 8: prowl.PrioEmergency,
+	}
+	for level, prio := range cases {
+		if prowl.PriorityForLevel(level) != prio {
+			t.Errorf("level %s should map to priority %d", level, prio)
+		}
+	}
+}
+
+func ExampleNewSlogHandler() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		Application: "prowlgo Example",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	//Everything goes to the text handler, warnings and above go to prowl as well.
+	next := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	logger := slog.New(prowl.NewSlogHandler(next, client, &prowl.SlogHandlerOptions{
+		Level: slog.LevelWarn,
+		Sync:  true,
+	}))
+
+	logger.Info("starting up")
+	logger.Warn("disk almost full", "mount", "/var", "free", "3%")
+
+	//output:
+	//level=INFO msg="starting up"
+	//level=WARN msg="disk almost full" mount=/var free=3%
+}
@@ -0,0 +1,116 @@
+package prowlgo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultThrottleWindow = 10 * time.Minute
+	//maxThrottleAge is the time after which the suppressed repeats of a key are forgotten.
+	maxThrottleAge = 24 * time.Hour
+)
+
+// ThrottleConfig enables the throttle of the client (see Config.Throttle). The throttle sends at
+// most one notification per key within Window. The key is the DedupKey of the notification or
+// its event if no DedupKey is defined. Notifications for different recipients are throttled
+// independently.
+//
+// Repeats within the window are suppressed: they are not sent and Send reports them with
+// StatusSuppressed. The number of suppressed repeats is added to the description of the next
+// notification with the same key, e.g. "(suppressed 37 repeats)".
+type ThrottleConfig struct {
+	//Window is the time after a notification in which repeats are suppressed.
+	//Defaults to 10 minutes.
+	Window time.Duration
+}
+
+func (tc *ThrottleConfig) validate() error {
+	if tc.Window < 0 {
+		return newFieldError("Throttle", "window must not be negative")
+	}
+	if tc.Window == 0 {
+		tc.Window = defaultThrottleWindow
+	}
+	return nil
+}
+
+type throttleEntry struct {
+	last       time.Time
+	suppressed int
+}
+
+// throttle keeps track of the notifications sent per key.
+type throttle struct {
+	config ThrottleConfig
+
+	mu      sync.Mutex
+	entries map[string]*throttleEntry
+}
+
+func newThrottle(config ThrottleConfig) *throttle {
+	return &throttle{config: config, entries: make(map[string]*throttleEntry)}
+}
+
+// throttleKey returns the key a notification is throttled by.
+func throttleKey(n Notification, apiKeys []string) string {
+	key := n.DedupKey
+	if len(key) == 0 {
+		key = strings.TrimSpace(n.Event)
+	}
+	return key + "\x00" + strings.Join(apiKeys, ",")
+}
+
+// pass reports whether a notification with the given key may be sent now. If so, suppressed is
+// the number of repeats suppressed since the last notification with the key and prev is the
+// time of that notification. Pass both to undo if the notification can't be sent after all.
+func (th *throttle) pass(key string, now time.Time) (ok bool, suppressed int, prev time.Time) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	e, found := th.entries[key]
+	if found && now.Sub(e.last) < th.config.Window {
+		e.suppressed++
+		return false, 0, time.Time{}
+	}
+	if !found {
+		th.prune(now)
+		e = &throttleEntry{}
+		th.entries[key] = e
+	}
+	suppressed, prev = e.suppressed, e.last
+	e.last, e.suppressed = now, 0
+	return true, suppressed, prev
+}
+
+// undo reverts pass for a notification that could not be sent.
+func (th *throttle) undo(key string, suppressed int, prev time.Time) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	if e, ok := th.entries[key]; ok {
+		e.last = prev
+		e.suppressed += suppressed
+	}
+}
+
+// prune forgets keys whose window has passed without suppressed repeats and keys that have
+// not been sent for a long time. Must be called with th.mu held.
+func (th *throttle) prune(now time.Time) {
+	for key, e := range th.entries {
+		age := now.Sub(e.last)
+		if (age >= th.config.Window && e.suppressed == 0) || age >= maxThrottleAge {
+			delete(th.entries, key)
+		}
+	}
+}
+
+// suppressedNote returns the note telling about suppressed repeats.
+func suppressedNote(suppressed int) string {
+	if suppressed == 1 {
+		return "(suppressed 1 repeat)"
+	}
+	return fmt.Sprintf("(suppressed %d repeats)", suppressed)
+}
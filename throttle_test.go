@@ -0,0 +1,121 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestThrottle(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	if _, err := prowl.NewClient(prowl.Config{Throttle: &prowl.ThrottleConfig{Window: -1}}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("negative window should produce an error: %v", err)
+	}
+
+	alice, bob := multipleValidAPIKeys[0], multipleValidAPIKeys[1]
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:    mock.BaseURL(),
+		APIKeys:    []string{alice},
+		Recipients: map[string]string{"alice": alice, "bob": bob},
+		Throttle:   &prowl.ThrottleConfig{Window: 200 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a flapping check: only the first notification is sent
+	for i := 0; i < 38; i++ {
+		result, err := client.Send(context.Background(), prowl.Notification{Event: "Disk full", Description: "/var"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := prowl.StatusSuppressed; i > 0 && result.Status != want {
+			t.Errorf("repeat %d should be %s, got %s", i, want, result.Status)
+		}
+	}
+	if n := len(mock.Adds()); n != 1 {
+		t.Errorf("only one notification should be sent, got %d", n)
+	}
+
+	//other events, dedup keys and recipients are throttled independently
+	if _, err := client.Add(prowl.PrioNormal, "Load high", "server 1"); err != nil {
+		t.Error(err)
+	}
+	for _, n := range []prowl.Notification{
+		{Event: "Disk full", DedupKey: "disk /home"},
+		{Event: "Disk full", To: []string{"bob"}},
+		{Event: "Load high", DedupKey: "load", Description: "server 2"},
+		{Event: "Load high", DedupKey: "load", Description: "server 3"},
+	} {
+		if _, err := client.Send(context.Background(), n); err != nil {
+			t.Error(err)
+		}
+	}
+	if n := len(mock.Adds()); n != 5 {
+		t.Errorf("unexpected number of notifications %d", n)
+	}
+
+	//the next notification after the window tells how many repeats have been suppressed
+	<-time.After(250 * time.Millisecond)
+	if _, err := client.Add(prowl.PrioNormal, "Disk full", "/var"); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); last.Params.Get("description") != "/var (suppressed 37 repeats)" {
+		t.Errorf("unexpected description %q", last.Params.Get("description"))
+	}
+	if _, err := client.Add(prowl.PrioNormal, "Load high", "server 4"); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); last.Params.Get("description") != "server 4" {
+		t.Errorf("unexpected description %q", last.Params.Get("description"))
+	}
+
+	//a notification that can't be sent does not count
+	<-time.After(250 * time.Millisecond)
+	mock.SetInternalError(true)
+	if _, err := client.Add(prowl.PrioNormal, "Disk full", "/var"); err == nil {
+		t.Fatal("notification should fail")
+	}
+	mock.SetInternalError(false)
+	result, err := client.Send(context.Background(), prowl.Notification{Event: "Disk full", Description: "/var"})
+	if err != nil || result.Status != prowl.StatusSent {
+		t.Fatalf("notification should be sent %+v: %v", result, err)
+	}
+}
+
+func ExampleThrottleConfig() {
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:     mock.BaseURL(),
+		APIKeys:     aValidAPIKey,
+		Application: "prowlgo Example",
+		Throttle:    &prowl.ThrottleConfig{Window: 15 * time.Minute},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for i := 0; i < 3; i++ {
+		result, err := client.Send(context.Background(), prowl.Notification{
+			Event:       "Check failed",
+			Description: "ping example.com",
+			DedupKey:    "ping example.com",
+		})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(result.Status)
+	}
+
+	//output:
+	//sent
+	//suppressed
+	//suppressed
+}
@@ -0,0 +1,214 @@
+package prowlgo
+
+import (
+	"context"
+	"sort"
+)
+
+// AddToTopic is the same as Add() but the notification is only sent to the subscribers of
+// the topic (see Config.Topics). Nothing is sent if nobody subscribed to the topic.
+func (clt *Client) AddToTopic(topic string, priority int, event string, description string) (remaining int, err error) {
+	return clt.AddToTopicContext(context.Background(), topic, priority, event, description)
+}
+
+// AddToTopicContext is the same as AddToTopic() but the request to the prowl server is bound
+// to the provided context.
+func (clt *Client) AddToTopicContext(ctx context.Context, topic string, priority int, event string, description string) (remaining int, err error) {
+	if len(topic) == 0 {
+		return clt.remainingCalls(), newFieldError("topic", "topic must not be empty")
+	}
+	result, err := clt.Send(ctx, Notification{
+		Topic:       topic,
+		Priority:    priority,
+		Event:       event,
+		Description: description,
+	})
+	return result.Remaining, err
+}
+
+// AddGroupMember adds the named recipient to a group. The group is created if it does not
+// exist yet. Duplicates are handled silently.
+func (clt *Client) AddGroupMember(group string, recipient string) error {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	if err := clt.checkGroupMember(group, recipient); err != nil {
+		return err
+	}
+	clt.groups.add(group, recipient)
+	return nil
+}
+
+// RemoveGroupMember removes the named recipient from a group. A group without members is
+// removed, including its subscriptions. Unknown names are handled silently.
+func (clt *Client) RemoveGroupMember(group string, recipient string) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	clt.removeGroupMember(group, recipient)
+}
+
+// Groups returns the names of all groups in alphabetical order.
+func (clt *Client) Groups() []string {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	return clt.groups.owners()
+}
+
+// GroupMembers returns the names of the members of a group in alphabetical order.
+func (clt *Client) GroupMembers(group string) []string {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	return clt.groups.members(group)
+}
+
+// Subscribe subscribes a recipient, a group or a rotation to a topic. Subscribing a group is
+// the same as subscribing all of its current and future members. Subscribing a rotation
+// sends the notifications of the topic to whoever is on call. Duplicates are handled silently.
+func (clt *Client) Subscribe(topic string, name string) error {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	if err := clt.checkSubscriber(topic, name); err != nil {
+		return err
+	}
+	clt.topics.add(topic, name)
+	return nil
+}
+
+// Unsubscribe ends the subscription of a recipient, a group or a rotation to a topic. Unknown
+// names are handled silently.
+func (clt *Client) Unsubscribe(topic string, name string) {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	clt.topics.remove(topic, name)
+}
+
+// Topics returns all topics with at least one subscriber in alphabetical order.
+func (clt *Client) Topics() []string {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	return clt.topics.owners()
+}
+
+// Subscribers returns the names of the recipients, groups and rotations subscribed to a topic
+// in alphabetical order.
+func (clt *Client) Subscribers(topic string) []string {
+	clt.mu.Lock()
+	defer clt.mu.Unlock()
+
+	return clt.topics.members(topic)
+}
+
+// checkGroupMember validates a new member of a group. Must be called with clt.mu held.
+func (clt *Client) checkGroupMember(group string, recipient string) error {
+	if len(group) == 0 {
+		return newFieldError("Groups", "group name must not be empty")
+	}
+	if _, ok := clt.recipients[group]; ok || clt.rotations[group] != nil {
+		return newFieldError("Groups", "group %s must not have the name of a recipient or a rotation", group)
+	}
+	if _, ok := clt.recipients[recipient]; !ok {
+		return newFieldError("Groups", "member %s of group %s is not a recipient", recipient, group)
+	}
+	return nil
+}
+
+// checkSubscriber validates a new subscription. Must be called with clt.mu held.
+func (clt *Client) checkSubscriber(topic string, name string) error {
+	if len(topic) == 0 {
+		return newFieldError("Topics", "topic must not be empty")
+	}
+	if !clt.knownTarget(name) {
+		return newFieldError("Topics", "subscriber %s of topic %s is neither a recipient nor a group nor a rotation", name, topic)
+	}
+	return nil
+}
+
+// knownTarget reports whether name is a recipient, a group or a rotation. Must be called with
+// clt.mu held.
+func (clt *Client) knownTarget(name string) bool {
+	_, ok := clt.recipients[name]
+	return ok || clt.groups.has(name) || clt.rotations[name] != nil
+}
+
+// removeGroupMember removes a member from a group and drops the subscriptions of the group
+// once it is empty. Must be called with clt.mu held.
+func (clt *Client) removeGroupMember(group string, recipient string) {
+	clt.groups.remove(group, recipient)
+	if !clt.groups.has(group) {
+		clt.topics.removeMember(group)
+	}
+}
+
+// forgetRecipient removes a recipient from all groups, rotations, topics and quiet hours. Must
+// be called with clt.mu held.
+func (clt *Client) forgetRecipient(name string) {
+	clt.forgetRotationMember(name)
+	clt.forgetQuietRecipient(name)
+	for _, group := range clt.groups.owners() {
+		clt.removeGroupMember(group, name)
+	}
+	clt.topics.removeMember(name)
+}
+
+// membership maps the names of groups or topics to their members.
+type membership map[string]map[string]bool
+
+func (m membership) add(owner string, member string) {
+	if m[owner] == nil {
+		m[owner] = make(map[string]bool)
+	}
+	m[owner][member] = true
+}
+
+// remove removes a member. Owners without members are dropped.
+func (m membership) remove(owner string, member string) {
+	delete(m[owner], member)
+	if len(m[owner]) == 0 {
+		delete(m, owner)
+	}
+}
+
+// removeMember removes member from all owners.
+func (m membership) removeMember(member string) {
+	for owner := range m {
+		m.remove(owner, member)
+	}
+}
+
+func (m membership) has(owner string) bool {
+	return len(m[owner]) > 0
+}
+
+func (m membership) owners() (owners []string) {
+	for owner := range m {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	return
+}
+
+func (m membership) members(owner string) (members []string) {
+	for member := range m[owner] {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return
+}
+
+// config returns the memberships in the format of Config.Groups and Config.Topics.
+func (m membership) config() map[string][]string {
+	if len(m) == 0 {
+		return nil
+	}
+	ret := make(map[string][]string, len(m))
+	for owner := range m {
+		ret[owner] = m.members(owner)
+	}
+	return ret
+}
@@ -0,0 +1,170 @@
+package prowlgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	prowl "github.com/tweithoener/prowlgo"
+)
+
+func TestTopics(t *testing.T) {
+	mock.Reset()
+	defer mock.Reset()
+
+	alice, bob, carol := multipleValidAPIKeys[0], multipleValidAPIKeys[1], multipleValidAPIKeys[2]
+	recipients := map[string]string{"alice": alice, "bob": bob, "carol": carol}
+
+	if _, err := prowl.NewClient(prowl.Config{Recipients: recipients, Groups: map[string][]string{"dba": {"dave"}}}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown group member should produce an error: %v", err)
+	}
+	if _, err := prowl.NewClient(prowl.Config{Recipients: recipients, Groups: map[string][]string{"bob": {"alice"}}}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("group with the name of a recipient should produce an error: %v", err)
+	}
+	if _, err := prowl.NewClient(prowl.Config{Recipients: recipients, Topics: map[string][]string{"db": {"dave"}}}); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown subscriber should produce an error: %v", err)
+	}
+
+	client, err := prowl.NewClient(prowl.Config{
+		BaseURL:    mock.BaseURL(),
+		Recipients: recipients,
+		Groups:     map[string][]string{"dba": {"alice", "bob"}},
+		Topics:     map[string][]string{"db": {"dba"}, "billing": {"carol"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//topics go to their subscribers, groups are expanded
+	if _, err := client.AddToTopic("db", prowl.PrioHigh, "DB", "replication lag"); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); !sameKeys(last.APIKeys(), alice, bob) {
+		t.Errorf("unexpected api keys %v", last.APIKeys())
+	}
+	if _, err := client.AddTo([]string{"dba", "bob", "carol"}, prowl.PrioNormal, "Event", "Description"); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); !sameKeys(last.APIKeys(), alice, bob, carol) {
+		t.Errorf("unexpected api keys %v", last.APIKeys())
+	}
+
+	//nothing is sent to topics without subscribers
+	requests := len(mock.Requests())
+	if _, err := client.AddToTopic("deploys", prowl.PrioNormal, "Deploy", "v1.2.3"); err != nil {
+		t.Error(err)
+	}
+	if len(mock.Requests()) != requests {
+		t.Error("nothing should be sent to a topic without subscribers")
+	}
+	if result, err := client.Send(context.Background(), prowl.Notification{Topic: "deploys", Event: "Deploy"}); err != nil || result.Status != prowl.StatusDropped {
+		t.Errorf("notification to a topic without subscribers should be dropped: %v %v", result.Status, err)
+	}
+	if _, err := client.AddToTopic("", prowl.PrioNormal, "Deploy", "v1.2.3"); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("empty topic should produce an error: %v", err)
+	}
+
+	//subscriptions change at runtime
+	if err := client.Subscribe("deploys", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Subscribe("deploys", "dave"); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown subscriber should produce an error: %v", err)
+	}
+	if _, err := client.AddToTopic("deploys", prowl.PrioNormal, "Deploy", "v1.2.3"); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := mock.LastAdd(); !sameKeys(last.APIKeys(), carol) {
+		t.Errorf("unexpected api keys %v", last.APIKeys())
+	}
+	client.Unsubscribe("deploys", "carol")
+	if !reflect.DeepEqual(client.Topics(), []string{"billing", "db"}) {
+		t.Errorf("unexpected topics %v", client.Topics())
+	}
+
+	//groups change at runtime
+	if err := client.AddGroupMember("dba", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddGroupMember("dba", "dave"); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("unknown group member should produce an error: %v", err)
+	}
+	if !reflect.DeepEqual(client.GroupMembers("dba"), []string{"alice", "bob", "carol"}) {
+		t.Errorf("unexpected group members %v", client.GroupMembers("dba"))
+	}
+	if err := client.AddRecipient("dba", alice); !errors.Is(err, prowl.ErrInvalidArgument) {
+		t.Errorf("recipient with the name of a group should produce an error: %v", err)
+	}
+
+	//removed recipients leave their groups and topics, empty groups lose their subscriptions
+	client.RemoveRecipient("carol")
+	if !reflect.DeepEqual(client.GroupMembers("dba"), []string{"alice", "bob"}) {
+		t.Errorf("unexpected group members %v", client.GroupMembers("dba"))
+	}
+	if !reflect.DeepEqual(client.Subscribers("billing"), []string(nil)) {
+		t.Errorf("unexpected subscribers %v", client.Subscribers("billing"))
+	}
+	client.RemoveGroupMember("dba", "alice")
+	if err := client.RemoveAPIKey(bob); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Groups()) != 0 || len(client.Topics()) != 0 {
+		t.Errorf("unexpected groups %v and topics %v", client.Groups(), client.Topics())
+	}
+
+	//groups and topics survive the round trip through the config
+	if err := client.AddGroupMember("dba", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Subscribe("db", "dba"); err != nil {
+		t.Fatal(err)
+	}
+	config := client.Config()
+	if !reflect.DeepEqual(config.Groups, map[string][]string{"dba": {"alice"}}) ||
+		!reflect.DeepEqual(config.Topics, map[string][]string{"db": {"dba"}}) {
+		t.Errorf("unexpected groups %v and topics %v in config", config.Groups, config.Topics)
+	}
+	copied, err := prowl.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(copied.Subscribers("db"), []string{"dba"}) {
+		t.Errorf("unexpected subscribers %v", copied.Subscribers("db"))
+	}
+}
+
+func ExampleClient_AddToTopic() {
+	client, err := prowl.NewBuilder().
+		SetBaseURL(mock.BaseURL()).
+		SetApplication("prowlgo Example").
+		AddRecipient("alice", "e192384beae856efa6dda87d6a00837cf968bd8c").
+		AddRecipient("bob", "e19238423ae856efa6ddadf34a00837cf968bd8c").
+		AddGroupMember("dba", "alice").
+		AddGroupMember("dba", "bob").
+		Subscribe("db", "dba").
+		Build()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	//Bob also wants to know about deployments
+	if err := client.Subscribe("deploys", "bob"); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if _, err := client.AddToTopic("db", prowl.PrioHigh, "Database", "replication lag is 5 minutes"); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, topic := range client.Topics() {
+		fmt.Println(topic, client.Subscribers(topic))
+	}
+
+	//output:
+	//db [dba]
+	//deploys [bob]
+}